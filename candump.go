@@ -0,0 +1,261 @@
+package goelster
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/brutella/can"
+)
+
+// CandumpRecord is a line of a candump log like
+// (1502983405.312412) can0 680#3100FA0931
+type CandumpRecord struct {
+	Time      time.Time
+	Interface string
+	Frame     can.Frame
+}
+
+// String formats the record as written by candump -l
+func (r CandumpRecord) String() string {
+	frm := r.Frame
+
+	var id string
+	if frm.ID&canEFFFlag != 0 {
+		id = fmt.Sprintf("%08X", frm.ID&canEFFMask)
+	} else {
+		id = fmt.Sprintf("%03X", frm.ID&canSFFMask)
+	}
+
+	data := "R"
+	if frm.ID&canRTRFlag == 0 {
+		data = fmt.Sprintf("%X", frm.Data[:frm.Length])
+	}
+
+	return fmt.Sprintf("(%d.%06d) %s %s#%s", r.Time.Unix(), r.Time.Nanosecond()/1000, r.Interface, id, data)
+}
+
+// ParseCandump parses a line of a candump log
+func ParseCandump(line string) (CandumpRecord, error) {
+	var rec CandumpRecord
+
+	fields := strings.Fields(line)
+	if len(fields) != 3 || !strings.HasPrefix(fields[0], "(") || !strings.HasSuffix(fields[0], ")") {
+		return rec, fmt.Errorf("invalid candump line '%s'", line)
+	}
+
+	ts, err := strconv.ParseFloat(strings.Trim(fields[0], "()"), 64)
+	if err != nil {
+		return rec, fmt.Errorf("invalid candump timestamp '%s'", fields[0])
+	}
+	sec, frac := math.Modf(ts)
+	rec.Time = time.Unix(int64(sec), int64(math.Round(frac*1e6))*1000)
+	rec.Interface = fields[1]
+
+	i := strings.Index(fields[2], "#")
+	if i < 0 {
+		return rec, fmt.Errorf("invalid candump frame '%s'", fields[2])
+	}
+	id, data := fields[2][:i], fields[2][i+1:]
+
+	n, err := strconv.ParseUint(id, 16, 32)
+	switch {
+	case err != nil:
+		return rec, fmt.Errorf("invalid candump frame '%s'", fields[2])
+	case len(id) == 8 && n <= canEFFMask:
+		rec.Frame.ID = uint32(n) | canEFFFlag
+	case len(id) == 3 && n <= canSFFMask:
+		rec.Frame.ID = uint32(n)
+	default:
+		return rec, fmt.Errorf("invalid candump frame '%s'", fields[2])
+	}
+
+	if strings.HasPrefix(data, "R") {
+		rec.Frame.ID |= canRTRFlag
+		return rec, nil
+	}
+
+	// CAN FD frames (##) and odd or oversized payloads are not supported
+	if len(data)%2 != 0 || len(data) > 2*can.MaxFrameDataLength {
+		return rec, fmt.Errorf("invalid candump frame '%s'", fields[2])
+	}
+
+	rec.Frame.Length = uint8(len(data) / 2)
+	for i := range rec.Frame.Data[:rec.Frame.Length] {
+		b, err := strconv.ParseUint(data[2*i:2*i+2], 16, 8)
+		if err != nil {
+			return rec, fmt.Errorf("invalid candump frame '%s'", fields[2])
+		}
+		rec.Frame.Data[i] = byte(b)
+	}
+
+	return rec, nil
+}
+
+// ReadCandump reads all records of a candump log. Empty lines and comments
+// starting with # are skipped.
+func ReadCandump(r io.Reader) ([]CandumpRecord, error) {
+	var records []CandumpRecord
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		s := strings.TrimSpace(scanner.Text())
+		if s == "" || strings.HasPrefix(s, "#") {
+			continue
+		}
+
+		rec, err := ParseCandump(s)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		records = append(records, rec)
+	}
+
+	return records, scanner.Err()
+}
+
+// CandumpWriter writes frames in the candump -l log format
+type CandumpWriter struct {
+	Interface string
+
+	mu  sync.Mutex
+	w   io.Writer
+	err error
+}
+
+// NewCandumpWriter creates a writer logging frames of the interface to w
+func NewCandumpWriter(w io.Writer, iface string) *CandumpWriter {
+	return &CandumpWriter{Interface: iface, w: w}
+}
+
+// WriteFrame logs a frame received at time t. Errors are kept and returned
+// by Err.
+func (c *CandumpWriter) WriteFrame(t time.Time, frm can.Frame) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.err == nil {
+		rec := CandumpRecord{Time: t, Interface: c.Interface, Frame: frm}
+		_, c.err = fmt.Fprintln(c.w, rec)
+	}
+
+	return c.err
+}
+
+// Err returns the first write error
+func (c *CandumpWriter) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+// Record writes all frames received from the transport until it is closed
+func Record(t Transport, w *CandumpWriter) error {
+	unsubscribe := t.Subscribe(func(frm can.Frame) {
+		w.WriteFrame(time.Now(), frm)
+	})
+	defer unsubscribe()
+
+	<-t.Done()
+
+	return w.Err()
+}
+
+// replayTransport feeds the frames of a candump log to its subscribers
+type replayTransport struct {
+	handlers
+	records []CandumpRecord
+	speed   float64
+	start   sync.Once
+	once    sync.Once
+	closed  chan struct{}
+	done    chan struct{}
+}
+
+// OpenReplay replays the candump log file. Speed 1 replays in original
+// timing, larger values accelerate and 0 replays as fast as possible.
+func OpenReplay(path string, speed float64) (Transport, error) {
+	if speed < 0 {
+		return nil, fmt.Errorf("invalid replay speed %v", speed)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	records, err := ReadCandump(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	return NewReplayTransport(records, speed), nil
+}
+
+// NewReplayTransport replays the records. Replay starts with the first
+// subscription.
+func NewReplayTransport(records []CandumpRecord, speed float64) Transport {
+	return &replayTransport{
+		records: records,
+		speed:   speed,
+		closed:  make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+}
+
+func (t *replayTransport) Subscribe(fn func(can.Frame)) func() {
+	unsubscribe := t.handlers.Subscribe(fn)
+	t.start.Do(func() { go t.run() })
+	return unsubscribe
+}
+
+func (t *replayTransport) run() {
+	defer close(t.done)
+
+	start := time.Now()
+	for _, rec := range t.records {
+		if t.speed > 0 {
+			offset := rec.Time.Sub(t.records[0].Time)
+			delay := time.Until(start.Add(time.Duration(float64(offset) / t.speed)))
+
+			select {
+			case <-time.After(delay):
+			case <-t.closed:
+				return
+			}
+		}
+
+		select {
+		case <-t.closed:
+			return
+		default:
+			t.dispatch(rec.Frame)
+		}
+	}
+}
+
+// Publish fails since a recorded bus cannot be written
+func (t *replayTransport) Publish(frm can.Frame) error {
+	return errors.New("cannot publish to replayed bus")
+}
+
+func (t *replayTransport) Done() <-chan struct{} {
+	return t.done
+}
+
+func (t *replayTransport) Close() error {
+	t.once.Do(func() {
+		close(t.closed)
+		// done is only closed by run once replay has started
+		t.start.Do(func() { close(t.done) })
+	})
+	return nil
+}
@@ -0,0 +1,171 @@
+package goelster
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/brutella/can"
+)
+
+func TestCandumpRecord(t *testing.T) {
+	tests := []struct {
+		s   string
+		frm can.Frame
+	}{
+		{"(1502983405.312412) can0 680#3100FA0931", canFrame(0x680, 0x31, 0x00, 0xFA, 0x09, 0x31)},
+		{"(1502983405.000001) can0 001#", canFrame(0x001)},
+		{"(1502983405.999999) vcan1 12345678#42", can.Frame{ID: canEFFFlag | 0x12345678, Length: 1, Data: [8]byte{0x42}}},
+		{"(0.000000) can0 680#R", can.Frame{ID: canRTRFlag | 0x680}},
+	}
+
+	for _, tc := range tests {
+		rec, err := ParseCandump(tc.s)
+		if err != nil || rec.Frame != tc.frm {
+			t.Errorf("ParseCandump(%s) incorrect, got: %v %v, want: %v.", tc.s, rec.Frame, err, tc.frm)
+		}
+
+		if s := rec.String(); s != tc.s {
+			t.Errorf("String incorrect, got: %s, want: %s.", s, tc.s)
+		}
+	}
+
+	for _, s := range []string{
+		"", "680#3100", "(1.0) can0", "(x) can0 680#31", "1.0 can0 680#31", "(1.0) can0 680",
+		"(1.0) can0 800#31", "(1.0) can0 68#31", "(1.0) can0 680#310", "(1.0) can0 680#3100FA09310102030405",
+		"(1.0) can0 680##1310", "(1.0) can0 680#XY",
+	} {
+		if rec, err := ParseCandump(s); err == nil {
+			t.Errorf("Expected error parsing '%s', got: %v.", s, rec)
+		}
+	}
+}
+
+func TestCandumpWriter(t *testing.T) {
+	var b bytes.Buffer
+	w := NewCandumpWriter(&b, "can0")
+
+	ts := time.Unix(1502983405, 312412000)
+	w.WriteFrame(ts, canFrame(0x680, 0x31, 0x00, 0xFA, 0x09, 0x31))
+	w.WriteFrame(ts.Add(time.Second), canFrame(0x180, 0xD2, 0x00, 0xFA, 0x09, 0x31, 0x00, 0x12))
+
+	want := "(1502983405.312412) can0 680#3100FA0931\n(1502983406.312412) can0 180#D200FA09310012\n"
+	if b.String() != want {
+		t.Errorf("CandumpWriter incorrect, got: %q, want: %q.", b.String(), want)
+	}
+
+	records, err := ReadCandump(&b)
+	if err != nil || len(records) != 2 || !records[1].Time.Equal(ts.Add(time.Second)) {
+		t.Errorf("ReadCandump incorrect, got: %v %v.", records, err)
+	}
+
+	if _, err := ReadCandump(strings.NewReader("# comment\n\n(1.0) can0 680#31\nfoo\n")); err == nil || !strings.HasPrefix(err.Error(), "line 4") {
+		t.Errorf("Expected error in line 4, got: %v.", err)
+	}
+}
+
+func TestRecord(t *testing.T) {
+	bus := NewVirtualBus(VirtualBusOptions{})
+	defer bus.Close()
+
+	sim, err := NewSimulator(0x180, SimulatorProfile{Values: map[string]float64{"AUSSENTEMP": 22.5}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go sim.Run(bus.Connect())
+
+	var b bytes.Buffer
+	recorder := bus.Connect()
+	done := make(chan error)
+	go func() { done <- Record(recorder, NewCandumpWriter(&b, "can0")) }()
+
+	// wait for the recorder subscription
+	time.Sleep(20 * time.Millisecond)
+
+	newTestClient(bus.Connect()).Read(context.Background(), 0x180, 0x000c)
+	time.Sleep(10 * time.Millisecond)
+	recorder.Close()
+
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := ReadCandump(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var frames []can.Frame
+	for _, rec := range records {
+		frames = append(frames, rec.Frame)
+	}
+
+	want := []can.Frame{
+		canFrame(0x680, 0x31, 0x00, 0x0C),
+		canFrame(0x180, 0xD2, 0x00, 0x0C, 0x00, 0xE1),
+	}
+	if len(frames) != len(want) || frames[0] != want[0] || frames[1] != want[1] {
+		t.Errorf("Recorded frames incorrect, got: %v, want: %v.", frames, want)
+	}
+}
+
+func TestReplay(t *testing.T) {
+	tests := []struct {
+		speed    string
+		min, max time.Duration
+	}{
+		{"0", 0, 100 * time.Millisecond},
+		{"4", 150 * time.Millisecond, time.Second},
+	}
+
+	for _, tc := range tests {
+		bus, err := OpenTransport("replay://testdata/wpm3.log?speed=" + tc.speed)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var values []string
+		start := time.Now()
+
+		bus.Subscribe(func(frm can.Frame) {
+			f, err := ParseFrame(frm)
+			if err != nil || !f.Type.HasValue() {
+				return
+			}
+
+			r := Reading(f.Register)
+			val, err := DecodeReading(f.Payload, r)
+			if err != nil {
+				t.Error(err)
+			}
+			values = append(values, r.Name+"="+val.String())
+		})
+
+		<-bus.Done()
+
+		if elapsed := time.Since(start); elapsed < tc.min || elapsed > tc.max {
+			t.Errorf("Replay at speed %s took %v, want: %v..%v.", tc.speed, elapsed, tc.min, tc.max)
+		}
+
+		want := "AUSSENTEMP=22.5 EINSTELL_SPEICHERSOLLTEMP=42.0 EINSTELL_SPEICHERSOLLTEMP=45.0"
+		if got := strings.Join(values, " "); got != want {
+			t.Errorf("Replayed values incorrect, got: %s, want: %s.", got, want)
+		}
+
+		if err := bus.Publish(canFrame(0x680)); err == nil {
+			t.Errorf("Expected error publishing to replay")
+		}
+	}
+
+	if _, err := OpenTransport("replay://testdata/missing.log"); !os.IsNotExist(err) {
+		t.Errorf("Expected missing file error, got: %v.", err)
+	}
+
+	// close before replay started
+	bus := NewReplayTransport(nil, 1)
+	bus.Close()
+	<-bus.Done()
+}
@@ -0,0 +1,180 @@
+package goelster
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"sync"
+
+	"github.com/brutella/can"
+)
+
+// cannelloni protocol constants
+const (
+	cannelloniVersion    = 2
+	cannelloniData       = 0
+	cannelloniHeaderSize = 5
+	cannelloniFDFlag     = 0x80
+)
+
+// EncodeCannelloni encodes frames as cannelloni data packet
+func EncodeCannelloni(seq byte, frms ...can.Frame) ([]byte, error) {
+	b := make([]byte, cannelloniHeaderSize, cannelloniHeaderSize+len(frms)*(5+can.MaxFrameDataLength))
+	b[0] = cannelloniVersion
+	b[1] = cannelloniData
+	b[2] = seq
+	binary.BigEndian.PutUint16(b[3:], uint16(len(frms)))
+
+	for _, frm := range frms {
+		if frm.Length > can.MaxFrameDataLength {
+			return nil, fmt.Errorf("invalid frame length %d", frm.Length)
+		}
+
+		b = append(b, 0, 0, 0, 0, frm.Length)
+		binary.BigEndian.PutUint32(b[len(b)-5:], frm.ID)
+		if frm.ID&canRTRFlag == 0 {
+			b = append(b, frm.Data[:frm.Length]...)
+		}
+	}
+
+	return b, nil
+}
+
+// DecodeCannelloni decodes the frames of a cannelloni data packet
+func DecodeCannelloni(b []byte) ([]can.Frame, error) {
+	if len(b) < cannelloniHeaderSize || b[0] != cannelloniVersion {
+		return nil, fmt.Errorf("invalid cannelloni packet % X", b)
+	}
+	if b[1] != cannelloniData {
+		return nil, nil
+	}
+
+	count := int(binary.BigEndian.Uint16(b[3:]))
+	frms := make([]can.Frame, 0, count)
+
+	b = b[cannelloniHeaderSize:]
+	for i := 0; i < count; i++ {
+		if len(b) < 5 {
+			return nil, fmt.Errorf("truncated cannelloni packet")
+		}
+
+		frm := can.Frame{ID: binary.BigEndian.Uint32(b)}
+		length := b[4]
+		b = b[5:]
+
+		// CAN FD frames carry an additional flags byte
+		if length&cannelloniFDFlag != 0 {
+			if len(b) < 1 {
+				return nil, fmt.Errorf("truncated cannelloni packet")
+			}
+			length &^= cannelloniFDFlag
+			b = b[1:]
+		}
+
+		n := int(length)
+		if frm.ID&canRTRFlag != 0 {
+			n = 0
+		}
+		if len(b) < n {
+			return nil, fmt.Errorf("truncated cannelloni packet")
+		}
+
+		// CAN FD payloads do not fit classic frames and are skipped
+		if length <= can.MaxFrameDataLength {
+			frm.Length = length
+			copy(frm.Data[:], b[:n])
+			frms = append(frms, frm)
+		}
+		b = b[n:]
+	}
+
+	return frms, nil
+}
+
+// cannelloniTransport exchanges frames with a cannelloni gateway via UDP
+type cannelloniTransport struct {
+	handlers
+	conn   *net.UDPConn
+	remote *net.UDPAddr
+	mu     sync.Mutex
+	seq    byte
+	done   chan struct{}
+}
+
+// OpenCannelloni sends frames to the cannelloni gateway at remote and
+// receives frames on the local address. An empty local address listens on
+// the port of the remote address.
+func OpenCannelloni(remote string, local string) (Transport, error) {
+	raddr, err := net.ResolveUDPAddr("udp", remote)
+	if err != nil {
+		return nil, err
+	}
+
+	if local == "" {
+		local = fmt.Sprintf(":%d", raddr.Port)
+	}
+
+	laddr, err := net.ResolveUDPAddr("udp", local)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := net.ListenUDP("udp", laddr)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &cannelloniTransport{
+		conn:   conn,
+		remote: raddr,
+		done:   make(chan struct{}),
+	}
+
+	go t.run()
+
+	return t, nil
+}
+
+// run dispatches received frames until the connection is closed
+func (t *cannelloniTransport) run() {
+	defer close(t.done)
+
+	b := make([]byte, 65536)
+	for {
+		n, err := t.conn.Read(b)
+		if err != nil {
+			return
+		}
+
+		frms, err := DecodeCannelloni(b[:n])
+		if err != nil {
+			continue
+		}
+
+		for _, frm := range frms {
+			t.dispatch(frm)
+		}
+	}
+}
+
+func (t *cannelloniTransport) Publish(frm can.Frame) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	b, err := EncodeCannelloni(t.seq, frm)
+	if err != nil {
+		return err
+	}
+	t.seq++
+
+	_, err = t.conn.WriteToUDP(b, t.remote)
+	return err
+}
+
+func (t *cannelloniTransport) Done() <-chan struct{} {
+	return t.done
+}
+
+func (t *cannelloniTransport) Close() error {
+	return t.conn.Close()
+}
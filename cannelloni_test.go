@@ -0,0 +1,107 @@
+package goelster
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/brutella/can"
+)
+
+func TestCannelloniPacket(t *testing.T) {
+	frms := []can.Frame{
+		canFrame(0x680, 0x31, 0x00, 0xFA),
+		canFrame(0x180, 0xD2, 0x00, 0xFA, 0x0A, 0x06, 0x01, 0xA4),
+		{ID: canEFFFlag | canRTRFlag | 0x12345678, Length: 2},
+	}
+
+	b, err := EncodeCannelloni(7, frms...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []byte{
+		2, 0, 7, 0, 3,
+		0x00, 0x00, 0x06, 0x80, 3, 0x31, 0x00, 0xFA,
+		0x00, 0x00, 0x01, 0x80, 7, 0xD2, 0x00, 0xFA, 0x0A, 0x06, 0x01, 0xA4,
+		0xD2, 0x34, 0x56, 0x78, 2,
+	}
+	if !bytes.Equal(b, want) {
+		t.Errorf("EncodeCannelloni incorrect, got: % X, want: % X.", b, want)
+	}
+
+	res, err := DecodeCannelloni(b)
+	if err != nil || len(res) != len(frms) {
+		t.Fatalf("DecodeCannelloni incorrect, got: %v %v.", res, err)
+	}
+	for i := range frms {
+		if res[i] != frms[i] {
+			t.Errorf("DecodeCannelloni incorrect, got: %v, want: %v.", res[i], frms[i])
+		}
+	}
+
+	// CAN FD frames are skipped
+	fd := []byte{2, 0, 0, 0, 2, 0x00, 0x00, 0x01, 0x00, 0x80 | 12, 0x00}
+	fd = append(fd, make([]byte, 12)...)
+	fd = append(fd, 0x00, 0x00, 0x06, 0x80, 1, 0x42)
+	if res, err := DecodeCannelloni(fd); err != nil || len(res) != 1 || res[0] != canFrame(0x680, 0x42) {
+		t.Errorf("DecodeCannelloni with CAN FD incorrect, got: %v %v.", res, err)
+	}
+
+	for _, b := range [][]byte{{}, {1, 0, 0, 0, 0}, {2, 0, 0, 0, 1, 0x00}, {2, 0, 0, 0, 1, 0, 0, 6, 0x80, 3, 0x31}} {
+		if _, err := DecodeCannelloni(b); err == nil {
+			t.Errorf("Expected error decoding % X", b)
+		}
+	}
+}
+
+func TestCannelloniTransport(t *testing.T) {
+	// stand-in gateway
+	gateway, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer gateway.Close()
+
+	bus, err := OpenTransport("cannelloni://" + gateway.LocalAddr().String() + "?local=127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bus.Close()
+
+	// answer requests
+	go func() {
+		b := make([]byte, 1500)
+		for {
+			n, addr, err := gateway.ReadFromUDP(b)
+			if err != nil {
+				return
+			}
+
+			frms, err := DecodeCannelloni(b[:n])
+			if err != nil || len(frms) != 1 || frms[0] != canFrame(0x680, 0x31, 0x00, 0xFA, 0x0A, 0x06) {
+				continue
+			}
+
+			res, _ := EncodeCannelloni(0, canFrame(0x180, 0xD2, 0x00, 0xFA, 0x0A, 0x06, 0x01, 0xA4))
+			gateway.WriteToUDP(res, addr)
+		}
+	}()
+
+	frm, err := newTestClient(bus).ReadFrame(context.Background(), 0x180, 0x0a06)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if frm != canFrame(0x180, 0xD2, 0x00, 0xFA, 0x0A, 0x06, 0x01, 0xA4) {
+		t.Errorf("Response incorrect, got: %v.", frm)
+	}
+
+	bus.Close()
+	select {
+	case <-bus.Done():
+	case <-time.After(time.Second):
+		t.Errorf("Transport not done after close")
+	}
+}
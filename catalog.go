@@ -0,0 +1,212 @@
+package goelster
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Catalog indexes register definitions by index and name
+type Catalog struct {
+	readings []*ElsterReading
+	byIndex  map[uint16]*ElsterReading
+	byName   map[string]*ElsterReading
+}
+
+// DefaultCatalog indexes the built-in ElsterReadings
+var DefaultCatalog *Catalog
+
+// NewCatalog creates a catalog of the given readings. If readings share an
+// index or name, the first one is used for lookups.
+func NewCatalog(readings []*ElsterReading) *Catalog {
+	c := &Catalog{
+		readings: readings,
+		byIndex:  make(map[uint16]*ElsterReading, len(readings)),
+		byName:   make(map[string]*ElsterReading, len(readings)),
+	}
+
+	for _, r := range readings {
+		if _, ok := c.byIndex[r.Index]; !ok {
+			c.byIndex[r.Index] = r
+		}
+
+		name := strings.ToUpper(r.Name)
+		if _, ok := c.byName[name]; !ok {
+			c.byName[name] = r
+		}
+	}
+
+	return c
+}
+
+// Readings returns all readings in definition order
+func (c *Catalog) Readings() []*ElsterReading {
+	return c.readings
+}
+
+// ByIndex returns the reading for the register index or nil
+func (c *Catalog) ByIndex(index uint16) *ElsterReading {
+	return c.byIndex[index]
+}
+
+// ByName returns the reading with the case-insensitive name or nil
+func (c *Catalog) ByName(name string) *ElsterReading {
+	return c.byName[strings.ToUpper(name)]
+}
+
+// match ranks how well a register name matches a search query
+type match int
+
+const (
+	noMatch match = iota
+	fuzzyMatch
+	substringMatch
+	prefixMatch
+	exactMatch
+)
+
+// normalizeName upper cases the name and removes separators
+func normalizeName(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '_' || r == '-' || r == ' ' {
+			return -1
+		}
+		return r
+	}, strings.ToUpper(s))
+}
+
+// matchName ranks name against the normalized query
+func matchName(name string, query string) match {
+	name = normalizeName(name)
+
+	switch {
+	case name == query:
+		return exactMatch
+	case strings.HasPrefix(name, query):
+		return prefixMatch
+	case strings.Contains(name, query):
+		return substringMatch
+	}
+
+	// fuzzy: all query characters appear in order
+	i := 0
+	for _, r := range name {
+		if i < len(query) && rune(query[i]) == r {
+			i++
+		}
+	}
+	if i == len(query) {
+		return fuzzyMatch
+	}
+
+	return noMatch
+}
+
+// Search returns all readings whose name matches the query, ignoring case and
+// separators. Exact matches come first, followed by prefix, substring and
+// fuzzy matches where all query characters appear in order.
+func (c *Catalog) Search(query string) []*ElsterReading {
+	query = normalizeName(query)
+	if query == "" {
+		return nil
+	}
+
+	type result struct {
+		r *ElsterReading
+		m match
+	}
+
+	var results []result
+	for _, r := range c.readings {
+		if m := matchName(r.Name, query); m != noMatch {
+			results = append(results, result{r, m})
+		}
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].m != results[j].m {
+			return results[i].m > results[j].m
+		}
+		return len(results[i].r.Name) < len(results[j].r.Name)
+	})
+
+	readings := make([]*ElsterReading, len(results))
+	for i, res := range results {
+		readings[i] = res.r
+	}
+
+	return readings
+}
+
+// Lookup finds a register by name, hex index or unique name prefix
+func (c *Catalog) Lookup(s string) (*ElsterReading, error) {
+	if r := c.ByName(s); r != nil {
+		return r, nil
+	}
+
+	// names like FA or BE are valid hex, fall back to names if no register has the index
+	if index, err := strconv.ParseUint(strings.TrimPrefix(strings.ToLower(s), "0x"), 16, 16); err == nil {
+		if r := c.ByIndex(uint16(index)); r != nil {
+			return r, nil
+		}
+	}
+
+	query := normalizeName(s)
+	var prefixed []*ElsterReading
+	for _, r := range c.readings {
+		if matchName(r.Name, query) >= prefixMatch {
+			prefixed = append(prefixed, r)
+		}
+	}
+
+	switch len(prefixed) {
+	case 0:
+		if suggestions := c.Search(s); len(suggestions) > 0 {
+			return nil, fmt.Errorf("unknown register '%s', did you mean %s?", s, names(suggestions, 5))
+		}
+		return nil, fmt.Errorf("unknown register '%s'", s)
+	case 1:
+		return prefixed[0], nil
+	default:
+		return nil, fmt.Errorf("ambiguous register '%s': %s", s, names(prefixed, 5))
+	}
+}
+
+// names lists the names of up to limit readings
+func names(readings []*ElsterReading, limit int) string {
+	var list []string
+	for i, r := range readings {
+		if i == limit {
+			list = append(list, "...")
+			break
+		}
+		list = append(list, r.Name)
+	}
+	return strings.Join(list, ", ")
+}
+
+// Check reports readings that share an index or name with a previous reading
+func (c *Catalog) Check() []error {
+	var errs []error
+
+	indexes := make(map[uint16]*ElsterReading)
+	seen := make(map[string]*ElsterReading)
+
+	for _, r := range c.readings {
+		if prev, ok := indexes[r.Index]; ok {
+			errs = append(errs, fmt.Errorf("duplicate index %04X: %s and %s", r.Index, prev.Name, r.Name))
+		} else {
+			indexes[r.Index] = r
+		}
+
+		name := strings.ToUpper(r.Name)
+		if prev, ok := seen[name]; ok {
+			errs = append(errs, fmt.Errorf("duplicate name %s: %04X and %04X", r.Name, prev.Index, r.Index))
+		} else {
+			seen[name] = r
+		}
+	}
+
+	return errs
+}
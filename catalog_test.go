@@ -0,0 +1,98 @@
+package goelster
+
+import (
+	"testing"
+)
+
+func TestCatalogLookup(t *testing.T) {
+	c := DefaultCatalog
+
+	if r := c.ByIndex(0x000c); r == nil || r.Name != "AUSSENTEMP" {
+		t.Errorf("ByIndex incorrect, got: %v, want: AUSSENTEMP.", r)
+	}
+	if r := c.ByName("aussentemp"); r == nil || r.Index != 0x000c {
+		t.Errorf("ByName incorrect, got: %v, want: 000C.", r)
+	}
+
+	tests := []struct {
+		query string
+		index uint16
+	}{
+		{"EINSTELL_SPEICHERSOLLTEMP2", 0x0a06},
+		{"einstell_speichersolltemp2", 0x0a06},
+		{"0a06", 0x0a06},
+		{"0x0A06", 0x0a06},
+		{"DCF", 0x0056}, // name looking like a hex index
+		{"FAB", 0x05d6}, // hex without register, prefix of FABRIKTEST_START
+		{"BA", 0x0128},
+		{"FEHLERMELD", 0x0001},
+	}
+
+	for _, tc := range tests {
+		r, err := c.Lookup(tc.query)
+		if err != nil {
+			t.Errorf("Lookup %s failed: %v", tc.query, err)
+		} else if r.Index != tc.index {
+			t.Errorf("Lookup %s incorrect, got: %04X, want: %04X.", tc.query, r.Index, tc.index)
+		}
+	}
+
+	for _, query := range []string{"EINSTELL_SPEICHER", "NOT_A_REGISTER", "fffe"} {
+		if r, err := c.Lookup(query); err == nil {
+			t.Errorf("Lookup %s expected error, got: %s.", query, r.Name)
+		}
+	}
+}
+
+func TestDefaultCatalogCheck(t *testing.T) {
+	for _, err := range DefaultCatalog.Check() {
+		t.Error(err)
+	}
+}
+
+func TestCatalogSearch(t *testing.T) {
+	c := NewCatalog([]*ElsterReading{
+		{Name: "SPEICHERISTTEMP", Index: 0x000e, Type: et_dec_val},
+		{Name: "EINSTELL_SPEICHERSOLLTEMP", Index: 0x0013, Type: et_dec_val},
+		{Name: "SPEICHERSOLLTEMP", Index: 0x0003, Type: et_dec_val},
+		{Name: "AUSSENTEMP", Index: 0x000c, Type: et_dec_val},
+	})
+
+	tests := []struct {
+		query    string
+		expected []uint16
+	}{
+		{"speichersoll", []uint16{0x0003, 0x0013}},
+		{"speicher_ist", []uint16{0x000e}},
+		{"spist", []uint16{0x000e, 0x0003, 0x0013}},
+		{"xyz", nil},
+	}
+
+	for _, tc := range tests {
+		results := c.Search(tc.query)
+		if len(results) != len(tc.expected) {
+			t.Errorf("Search %s incorrect, got: %d results, want: %d.", tc.query, len(results), len(tc.expected))
+			continue
+		}
+		for i, r := range results {
+			if r.Index != tc.expected[i] {
+				t.Errorf("Search %s result %d incorrect, got: %04X, want: %04X.", tc.query, i, r.Index, tc.expected[i])
+			}
+		}
+	}
+}
+
+func TestCatalogCheck(t *testing.T) {
+	c := NewCatalog([]*ElsterReading{
+		{Name: "A", Index: 0x0001},
+		{Name: "B", Index: 0x0001},
+		{Name: "a", Index: 0x0002},
+	})
+
+	if errs := c.Check(); len(errs) != 2 {
+		t.Errorf("Check incorrect, got: %v, want 2 errors.", errs)
+	}
+	if r := c.ByIndex(0x0001); r.Name != "A" {
+		t.Errorf("Duplicate index incorrect, got: %s, want: A.", r.Name)
+	}
+}
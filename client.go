@@ -0,0 +1,397 @@
+package goelster
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/brutella/can"
+)
+
+// ErrNoResponse is returned if a device did not answer a request
+var ErrNoResponse = errors.New("no response")
+
+// ClientOptions configures the requests sent to devices
+type ClientOptions struct {
+	Timeout  time.Duration // time to wait for a response
+	Retries  int           // additional requests if no response was received
+	Backoff  time.Duration // wait before the first retry, doubled for each further retry
+	Gap      time.Duration // minimum time between two requests
+	Parallel int           // maximum number of receivers with requests in flight, 0 is unlimited
+	Quiet    bool          // do not log request timing and retries
+}
+
+// DefaultClientOptions are suitable for WPM controllers on a quiet bus
+var DefaultClientOptions = ClientOptions{
+	Timeout:  100 * time.Millisecond,
+	Backoff:  50 * time.Millisecond,
+	Parallel: 4,
+}
+
+// writeSettleTime is the time given to the device to take over a written value
+const writeSettleTime = 200 * time.Millisecond
+
+// Client reads and writes registers of devices on the bus. Requests to
+// different receivers may be sent from several goroutines and are processed
+// in parallel, requests to the same receiver are sent one after another.
+type Client struct {
+	Sender  uint16 // CAN ID used for requests
+	Options ClientOptions
+
+	t    Transport
+	d    *dispatcher
+	mu   sync.Mutex
+	last time.Time
+}
+
+// NewClient creates a client sending requests as sender. The number of
+// parallel requests is taken from opts when the client is created.
+func NewClient(t Transport, sender uint16, opts ClientOptions) *Client {
+	return &Client{
+		Sender:  sender,
+		Options: opts,
+		t:       t,
+		d:       newDispatcher(t, sender, opts.Parallel),
+	}
+}
+
+// Close stops receiving responses
+func (c *Client) Close() {
+	c.d.unsubscribe()
+}
+
+// sleep waits for d or until the context is done
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// publish sends the frame after the minimum gap since the last request
+func (c *Client) publish(ctx context.Context, frm can.Frame) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := sleep(ctx, c.Options.Gap-time.Since(c.last)); err != nil {
+		return err
+	}
+	c.last = time.Now()
+
+	return c.t.Publish(frm)
+}
+
+// reading returns the definition of the register
+func reading(register uint16) (*ElsterReading, error) {
+	r := Reading(register)
+	if r == nil {
+		return nil, fmt.Errorf("unknown register %04X", register)
+	}
+	return r, nil
+}
+
+// ReadFrame requests the register and returns the response. If no response
+// is received within the timeout, the request is repeated as configured by
+// the client options.
+func (c *Client) ReadFrame(ctx context.Context, receiver uint16, register uint16) (can.Frame, error) {
+	release, err := c.d.acquire(ctx, receiver)
+	if err != nil {
+		return can.Frame{}, err
+	}
+	defer release()
+
+	return c.readFrame(ctx, receiver, register)
+}
+
+// readFrame reads the register like ReadFrame. The caller must hold the
+// receiver.
+func (c *Client) readFrame(ctx context.Context, receiver uint16, register uint16) (can.Frame, error) {
+	frm, err := Frame{
+		Sender:   c.Sender,
+		Receiver: receiver,
+		Type:     Read,
+		Register: register,
+	}.Marshal()
+	if err != nil {
+		return frm, err
+	}
+
+	res, done := c.d.expect(receiver, register)
+	defer done()
+
+	backoff := c.Options.Backoff
+	for attempt := 0; attempt <= c.Options.Retries; attempt++ {
+		if attempt > 0 {
+			if !c.Options.Quiet {
+				log.Printf("No response for register %04X, retrying", register)
+			}
+			if err := sleep(ctx, backoff); err != nil {
+				return frm, err
+			}
+			backoff *= 2
+		}
+
+		if err := c.publish(ctx, frm); err != nil {
+			return frm, err
+		}
+		startTime := time.Now()
+
+		timer := time.NewTimer(c.Options.Timeout)
+		select {
+		case <-timer.C:
+			// timeout
+		case <-ctx.Done():
+			timer.Stop()
+			return frm, ctx.Err()
+		case frm := <-res:
+			// result
+			timer.Stop()
+			if !c.Options.Quiet {
+				duration := time.Since(startTime)
+				log.Printf("CAN read took: %.fms", duration.Seconds()*1e3)
+			}
+			return frm, nil
+		}
+	}
+
+	return frm, fmt.Errorf("register %04X of %X: %w", register, receiver, ErrNoResponse)
+}
+
+// readPayload reads the raw payload of the register
+func (c *Client) readPayload(ctx context.Context, receiver uint16, register uint16) ([]byte, error) {
+	frm, err := c.ReadFrame(ctx, receiver, register)
+	if err != nil {
+		return nil, err
+	}
+
+	_, payload := Payload(frm.Data[:])
+	return payload, nil
+}
+
+// Read reads and decodes the register. Energy counters split across several
+// registers are combined into a single value in kWh.
+func (c *Client) Read(ctx context.Context, receiver uint16, register uint16) (Value, error) {
+	r, err := reading(register)
+	if err != nil {
+		return Value{}, err
+	}
+
+	if isEnergy(r) {
+		return c.readEnergy(ctx, receiver, r)
+	}
+
+	payload, err := c.readPayload(ctx, receiver, register)
+	if err != nil {
+		return Value{}, err
+	}
+
+	return DecodeReading(payload, r)
+}
+
+// readParts reads the raw values of all registers
+func (c *Client) readParts(ctx context.Context, receiver uint16, parts []*ElsterReading) ([]uint16, error) {
+	values := make([]uint16, len(parts))
+	for i, r := range parts {
+		payload, err := c.readPayload(ctx, receiver, r.Index)
+		if err != nil {
+			return nil, err
+		}
+
+		if bytes.Equal(payload, noValue) || len(payload) != 2 {
+			return nil, fmt.Errorf("register %s holds no value", r.Name)
+		}
+		values[i] = binary.BigEndian.Uint16(payload)
+	}
+	return values, nil
+}
+
+// readEnergy reads all parts of the energy counter r and returns the total in kWh.
+// The upper parts are read before and after the lowest part. If they differ,
+// a carry happened while reading and the parts are read again.
+func (c *Client) readEnergy(ctx context.Context, receiver uint16, r *ElsterReading) (Value, error) {
+	parts, err := EnergyParts(r)
+	if err != nil {
+		return Value{}, err
+	}
+
+	for retry := 0; retry < 3; retry++ {
+		upper, err := c.readParts(ctx, receiver, parts[1:])
+		if err != nil {
+			return Value{}, err
+		}
+
+		lower, err := c.readParts(ctx, receiver, parts[:1])
+		if err != nil {
+			return Value{}, err
+		}
+
+		check, err := c.readParts(ctx, receiver, parts[1:])
+		if err != nil {
+			return Value{}, err
+		}
+
+		if equalValues(upper, check) {
+			val := NewValue(CombineEnergy(parts, append(lower, upper...)), r.Type, nil)
+			val.Unit = "kWh"
+			return val, nil
+		}
+	}
+
+	return Value{}, fmt.Errorf("register %s changed while reading", r.Name)
+}
+
+// WriteRaw writes the raw payload to the register and verifies it by reading
+// it back. It returns the value read back.
+func (c *Client) WriteRaw(ctx context.Context, receiver uint16, register uint16, payload []byte) (Value, error) {
+	r, err := reading(register)
+	if err != nil {
+		return Value{}, err
+	}
+
+	if r.ReadOnly {
+		return Value{}, fmt.Errorf("register %s is read-only", r.Name)
+	}
+
+	// verify range of the raw value
+	if val, err := DecodeReading(payload, r); err == nil && r.Min < r.Max && !val.IsNull() {
+		if f := val.Float(); f < r.Min || f > r.Max {
+			return Value{}, fmt.Errorf("value %v out of range %v..%v for register %s", val, r.Min, r.Max, r.Name)
+		}
+	}
+
+	frm, err := Frame{
+		Sender:   c.Sender,
+		Receiver: receiver,
+		Type:     Write,
+		Register: register,
+		Payload:  payload,
+	}.Marshal()
+	if err != nil {
+		return Value{}, err
+	}
+
+	// no other request to the receiver until the write was read back
+	release, err := c.d.acquire(ctx, receiver)
+	if err != nil {
+		return Value{}, err
+	}
+	defer release()
+
+	if err := c.publish(ctx, frm); err != nil {
+		return Value{}, err
+	}
+
+	if err := sleep(ctx, writeSettleTime); err != nil {
+		return Value{}, err
+	}
+
+	res, err := c.readFrame(ctx, receiver, register)
+	if err != nil {
+		return Value{}, fmt.Errorf("reading back: %w", err)
+	}
+	_, readback := Payload(res.Data[:])
+
+	val, err := DecodeReading(readback, r)
+	if err != nil {
+		return val, err
+	}
+
+	if !bytes.Equal(readback, payload) {
+		return val, fmt.Errorf("write not confirmed, got: % X, want: % X", readback, payload)
+	}
+
+	return val, nil
+}
+
+// Write encodes the value according to the register definition, writes it
+// and verifies it by reading it back. It returns the value read back.
+func (c *Client) Write(ctx context.Context, receiver uint16, register uint16, val interface{}) (Value, error) {
+	r, err := reading(register)
+	if err != nil {
+		return Value{}, err
+	}
+
+	payload, err := EncodeReading(val, r)
+	if err != nil {
+		return Value{}, err
+	}
+
+	return c.WriteRaw(ctx, receiver, register, payload)
+}
+
+// ScanResult is a register value found by Scan
+type ScanResult struct {
+	Receiver uint16
+	Reading  *ElsterReading
+	Value    Value
+	Frame    *can.Frame // response, nil for combined energy counters
+}
+
+// Scan reads all registers of the catalog and calls fn for every register
+// holding a value. Unanswered registers are skipped.
+func (c *Client) Scan(ctx context.Context, receiver uint16, fn func(ScanResult)) error {
+	for _, r := range DefaultCatalog.Readings() {
+		if isEnergy(r) {
+			if val, err := c.readEnergy(ctx, receiver, r); err == nil {
+				fn(ScanResult{Receiver: receiver, Reading: r, Value: val})
+			}
+		} else if frm, err := c.ReadFrame(ctx, receiver, r.Index); err == nil {
+			_, payload := Payload(frm.Data[:])
+			if val, err := DecodeReading(payload, r); err == nil && !val.IsNull() {
+				fn(ScanResult{Receiver: receiver, Reading: r, Value: val, Frame: &frm})
+			}
+		}
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ScanReceivers scans all receivers in parallel as limited by the client
+// options. Calls of fn are serialized. The first error is returned once all
+// scans have finished.
+func (c *Client) ScanReceivers(ctx context.Context, receivers []uint16, fn func(ScanResult)) error {
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var first error
+
+	for _, receiver := range receivers {
+		wg.Add(1)
+		go func(receiver uint16) {
+			defer wg.Done()
+
+			err := c.Scan(ctx, receiver, func(res ScanResult) {
+				mu.Lock()
+				defer mu.Unlock()
+				fn(res)
+			})
+
+			mu.Lock()
+			if first == nil {
+				first = err
+			}
+			mu.Unlock()
+		}(receiver)
+	}
+
+	wg.Wait()
+
+	return first
+}
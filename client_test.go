@@ -0,0 +1,525 @@
+package goelster
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/brutella/can"
+)
+
+// newTestClient creates a quiet client sending as 680
+func newTestClient(t Transport) *Client {
+	opts := DefaultClientOptions
+	opts.Quiet = true
+	return NewClient(t, 0x680, opts)
+}
+
+func TestClientRead(t *testing.T) {
+	bus := NewVirtualBus(VirtualBusOptions{Latency: 5 * time.Millisecond})
+	defer bus.Close()
+
+	client := newTestClient(bus.Connect())
+	newResponder(bus.Connect(), 0x180, map[uint16][]byte{0x000c: {0xFF, 0xDD}})
+
+	ctx := context.Background()
+
+	frm, err := client.ReadFrame(ctx, 0x180, 0x000c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, payload := Payload(frm.Data[:]); !bytes.Equal(payload, []byte{0xFF, 0xDD}) {
+		t.Errorf("Payload incorrect, got: % X, want: FF DD.", payload)
+	}
+
+	// request and response
+	if traffic := bus.Traffic(); len(traffic) != 2 {
+		t.Errorf("Traffic incorrect, got: %d frames, want: 2.", len(traffic))
+	}
+
+	val, err := client.Read(ctx, 0x180, 0x000c)
+	if err != nil || val.Float() != -3.5 || val.Unit != "°C" {
+		t.Errorf("Value incorrect, got: %v %s %v, want: -3.5 °C.", val, val.Unit, err)
+	}
+
+	// other receiver does not answer
+	if _, err := client.Read(ctx, 0x301, 0x000c); !errors.Is(err, ErrNoResponse) {
+		t.Errorf("Expected no response, got: %v.", err)
+	}
+
+	if _, err := client.Read(ctx, 0x180, 0xffff); err == nil {
+		t.Errorf("Expected error for unknown register")
+	}
+}
+
+func TestClientTimeout(t *testing.T) {
+	bus := NewVirtualBus(VirtualBusOptions{Latency: 75 * time.Millisecond})
+	defer bus.Close()
+
+	newResponder(bus.Connect(), 0x180, map[uint16][]byte{0x000c: {0x00, 0xE1}})
+	client := newTestClient(bus.Connect())
+
+	start := time.Now()
+	if _, err := client.ReadFrame(context.Background(), 0x180, 0x000c); !errors.Is(err, ErrNoResponse) {
+		t.Errorf("Expected no response, got: %v.", err)
+	}
+	if time.Since(start) < 100*time.Millisecond {
+		t.Errorf("Timeout too short")
+	}
+
+	client.Options.Timeout = 300 * time.Millisecond
+	if _, err := client.ReadFrame(context.Background(), 0x180, 0x000c); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestClientLoss(t *testing.T) {
+	bus := NewVirtualBus(VirtualBusOptions{Loss: 1})
+	defer bus.Close()
+
+	client := newTestClient(bus.Connect())
+	newResponder(bus.Connect(), 0x180, map[uint16][]byte{0x000c: {0xFF, 0xDD}})
+
+	start := time.Now()
+	if _, err := client.ReadFrame(context.Background(), 0x180, 0x000c); !errors.Is(err, ErrNoResponse) {
+		t.Errorf("Expected no response, got: %v.", err)
+	}
+	if time.Since(start) < client.Options.Timeout {
+		t.Errorf("Timeout too short")
+	}
+
+	if traffic := bus.Traffic(); len(traffic) != 1 || !traffic[0].Dropped {
+		t.Errorf("Traffic incorrect, got: %v.", traffic)
+	}
+}
+
+func TestClientCancel(t *testing.T) {
+	bus := NewVirtualBus(VirtualBusOptions{Loss: 1})
+	defer bus.Close()
+
+	client := newTestClient(bus.Connect())
+	client.Options.Timeout = time.Second
+	client.Options.Retries = 3
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	if _, err := client.Read(ctx, 0x180, 0x000c); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected deadline exceeded, got: %v.", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Cancellation too slow, got: %v.", elapsed)
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+
+	if err := client.Scan(ctx, 0x180, func(ScanResult) {}); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected canceled scan, got: %v.", err)
+	}
+	if _, err := client.Write(ctx, 0x180, 0x0013, 42.0); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected canceled write, got: %v.", err)
+	}
+}
+
+func TestClientRetries(t *testing.T) {
+	bus := NewVirtualBus(VirtualBusOptions{})
+	defer bus.Close()
+
+	// device ignores the first two requests
+	device := bus.Connect()
+	var mu sync.Mutex
+	requests := 0
+
+	device.Subscribe(func(frm can.Frame) {
+		f, err := ParseFrame(frm)
+		if err != nil || f.Type != Read {
+			return
+		}
+
+		mu.Lock()
+		requests++
+		ignore := requests <= 2
+		mu.Unlock()
+
+		if !ignore {
+			res, _ := Frame{Sender: 0x180, Receiver: f.Sender, Type: Response, Register: f.Register, Payload: []byte{0x00, 0xE1}}.Marshal()
+			device.Publish(res)
+		}
+	})
+
+	client := newTestClient(bus.Connect())
+	client.Options.Timeout = 20 * time.Millisecond
+
+	if _, err := client.ReadFrame(context.Background(), 0x180, 0x000c); err == nil {
+		t.Errorf("Unexpected response")
+	}
+
+	client.Options.Retries = 2
+	client.Options.Backoff = 30 * time.Millisecond
+
+	start := time.Now()
+	if _, err := client.ReadFrame(context.Background(), 0x180, 0x000c); err != nil {
+		t.Error(err)
+	}
+
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("Retry too fast, got: %v, want: >= 50ms.", elapsed)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if requests != 3 {
+		t.Errorf("Requests incorrect, got: %d, want: 3.", requests)
+	}
+}
+
+func TestClientGap(t *testing.T) {
+	bus := NewVirtualBus(VirtualBusOptions{})
+	defer bus.Close()
+
+	newResponder(bus.Connect(), 0x180, map[uint16][]byte{})
+	client := newTestClient(bus.Connect())
+	client.Options.Gap = 50 * time.Millisecond
+
+	for i := 0; i < 3; i++ {
+		client.ReadFrame(context.Background(), 0x180, 0x000c)
+	}
+
+	traffic := bus.Traffic()
+	if len(traffic) != 6 {
+		t.Fatalf("Traffic incorrect, got: %d frames, want: 6.", len(traffic))
+	}
+
+	for i := 2; i < len(traffic); i += 2 {
+		if gap := traffic[i].Time.Sub(traffic[i-2].Time); gap < 50*time.Millisecond {
+			t.Errorf("Gap between requests too short, got: %v, want: >= 50ms.", gap)
+		}
+	}
+}
+
+func TestClientQuiet(t *testing.T) {
+	bus := NewVirtualBus(VirtualBusOptions{})
+	defer bus.Close()
+
+	newResponder(bus.Connect(), 0x180, map[uint16][]byte{})
+	client := newTestClient(bus.Connect())
+
+	var b bytes.Buffer
+	log.SetOutput(&b)
+	defer log.SetOutput(os.Stderr)
+
+	for _, quiet := range []bool{false, true} {
+		b.Reset()
+		client.Options.Quiet = quiet
+		client.ReadFrame(context.Background(), 0x180, 0x000c)
+
+		if logged := strings.Contains(b.String(), "CAN read took"); logged == quiet {
+			t.Errorf("Logging incorrect for quiet %v, got: %q.", quiet, b.String())
+		}
+	}
+}
+
+func TestClientWrite(t *testing.T) {
+	bus := NewVirtualBus(VirtualBusOptions{})
+	defer bus.Close()
+
+	client := newTestClient(bus.Connect())
+	res := newResponder(bus.Connect(), 0x180, map[uint16][]byte{})
+
+	ctx := context.Background()
+
+	val, err := client.WriteRaw(ctx, 0x180, 0x0a06, []byte{0x01, 0xA4})
+	if err != nil || val.Float() != 42 {
+		t.Errorf("Written value incorrect, got: %v %v, want: 42.", val, err)
+	}
+
+	res.mu.Lock()
+	if !bytes.Equal(res.values[0x0a06], []byte{0x01, 0xA4}) {
+		t.Errorf("Value not written, got: % X, want: 01 A4.", res.values[0x0a06])
+	}
+	res.mu.Unlock()
+
+	if val, err := client.Write(ctx, 0x180, 0x0a06, 45.5); err != nil || val.Float() != 45.5 {
+		t.Errorf("Written value incorrect, got: %v %v, want: 45.5.", val, err)
+	}
+
+	// enum value by name
+	if val, err := client.Write(ctx, 0x180, 0x0112, "Automatik"); err != nil || val.String() != "Automatik" {
+		t.Errorf("Written mode incorrect, got: %v %v, want: Automatik.", val, err)
+	}
+
+	res.mu.Lock()
+	if !bytes.Equal(res.values[0x0112], []byte{0x02, 0x00}) {
+		t.Errorf("Mode not written, got: % X, want: 02 00.", res.values[0x0112])
+	}
+	res.mu.Unlock()
+
+	// read-only register
+	if _, err := client.Write(ctx, 0x180, 0x000c, 10.0); err == nil {
+		t.Errorf("Expected error writing read-only register")
+	}
+
+	// no device
+	client.Options.Timeout = 20 * time.Millisecond
+	if _, err := client.WriteRaw(ctx, 0x301, 0x0a06, []byte{0x01, 0xA4}); !errors.Is(err, ErrNoResponse) {
+		t.Errorf("Expected no response, got: %v.", err)
+	}
+}
+
+func TestClientWriteExclusive(t *testing.T) {
+	bus := NewVirtualBus(VirtualBusOptions{})
+	defer bus.Close()
+
+	client := newTestClient(bus.Connect())
+	newResponder(bus.Connect(), 0x180, map[uint16][]byte{0x000c: {0x00, 0xE1}})
+
+	// read another register of the receiver while the write settles
+	read := make(chan error, 1)
+	bus.Connect().Subscribe(func(frm can.Frame) {
+		if f, _ := ParseFrame(frm); f.Type == Write {
+			go func() {
+				_, err := client.ReadFrame(context.Background(), 0x180, 0x000c)
+				read <- err
+			}()
+		}
+	})
+
+	if _, err := client.WriteRaw(context.Background(), 0x180, 0x0a06, []byte{0x01, 0xA4}); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-read; err != nil {
+		t.Fatal(err)
+	}
+
+	var requests []string
+	for _, rec := range bus.Traffic() {
+		if f, _ := ParseFrame(rec.Frame); f.Sender == 0x680 {
+			requests = append(requests, fmt.Sprintf("%s %04x", f.Type, f.Register))
+		}
+	}
+
+	want := "write 0a06, read 0a06, read 000c"
+	if got := strings.Join(requests, ", "); got != want {
+		t.Errorf("Requests incorrect, got: %s, want: %s.", got, want)
+	}
+}
+
+func TestClientReadEnergy(t *testing.T) {
+	bus := NewVirtualBus(VirtualBusOptions{})
+	defer bus.Close()
+
+	conn := bus.Connect()
+	client := newTestClient(conn)
+	res := newResponder(bus.Connect(), 0x180, map[uint16][]byte{
+		0x092c: {0x03, 0xE7}, // 999 kWh
+		0x092d: {0x00, 0x0C}, // 12 MWh
+	})
+
+	// carry between reading upper and lower part
+	conn.Subscribe(func(frm can.Frame) {
+		res.mu.Lock()
+		carry := res.reads[0x092d] == 1 && res.reads[0x092c] == 1
+		res.mu.Unlock()
+
+		if carry {
+			res.set(0x092c, []byte{0x00, 0x00})
+			res.set(0x092d, []byte{0x00, 0x0D})
+		}
+	})
+
+	val, err := client.Read(context.Background(), 0x180, 0x092d)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if val.Float() != 13000 || val.Unit != "kWh" {
+		t.Errorf("Energy incorrect, got: %v %s, want: 13000 kWh.", val, val.Unit)
+	}
+}
+
+func TestClientScan(t *testing.T) {
+	bus := NewVirtualBus(VirtualBusOptions{})
+	defer bus.Close()
+
+	sim, err := NewSimulator(0x180, SimulatorProfile{
+		Values: map[string]float64{"AUSSENTEMP": 22.5},
+		Raw:    map[string]string{"WAERMEERTRAG_WW_SUM_KWH": "03e7", "WAERMEERTRAG_WW_SUM_MWH": "000c"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go sim.Run(bus.Connect())
+
+	var values []string
+	err = newTestClient(bus.Connect()).Scan(context.Background(), 0x180, func(res ScanResult) {
+		values = append(values, fmt.Sprintf("%s=%v%s", res.Reading.Name, res.Value, res.Value.Unit))
+
+		// combined energy counters have no frame
+		if (res.Frame == nil) != isEnergy(res.Reading) {
+			t.Errorf("Frame incorrect for %s, got: %v.", res.Reading.Name, res.Frame)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "AUSSENTEMP=22.5°C WAERMEERTRAG_WW_SUM_KWH=0x03E7 WAERMEERTRAG_WW_SUM_MWH=12999.0kWh"
+	if got := strings.Join(values, " "); got != want {
+		t.Errorf("Scan incorrect, got: %s, want: %s.", got, want)
+	}
+}
+
+func TestDispatcher(t *testing.T) {
+	bus := NewVirtualBus(VirtualBusOptions{})
+	defer bus.Close()
+
+	d := newDispatcher(bus.Connect(), 0x680, 0)
+	defer d.unsubscribe()
+
+	c, done := d.expect(0x180, 0x0931)
+	defer done()
+
+	for _, typ := range []MessageType{Write, Read, Ack, WriteAck, WriteResponse, System, 0x0A} {
+		d.handle(canFrame(0x180, 0xD0|byte(typ), 0x00, 0xFA, 0x09, 0x31, 0x00, 0x27))
+		if len(c) != 0 {
+			t.Errorf("Message type %s incorrectly matched", typ)
+			<-c
+		}
+	}
+
+	for _, frm := range []can.Frame{
+		canFrame(0x301, 0xD2, 0x00, 0xFA, 0x09, 0x31, 0x00, 0x27), // other device
+		canFrame(0x180, 0xA2, 0x01, 0xFA, 0x09, 0x31, 0x00, 0x27), // other sender
+		canFrame(0x180, 0xD2, 0x00, 0xFA, 0x09, 0x32, 0x00, 0x27), // other register
+	} {
+		d.handle(frm)
+		if len(c) != 0 {
+			t.Errorf("Frame % X incorrectly matched", frm.Data)
+			<-c
+		}
+	}
+
+	d.handle(canFrame(0x180, 0xD2, 0x00, 0xFA, 0x09, 0x31, 0x00, 0x27))
+	if len(c) != 1 {
+		t.Errorf("Response not matched")
+	}
+
+	// duplicate responses are dropped
+	d.handle(canFrame(0x180, 0xD2, 0x00, 0xFA, 0x09, 0x31, 0x00, 0x28))
+	if frm := <-c; frm.Data[6] != 0x27 {
+		t.Errorf("Response incorrect, got: % X.", frm.Data)
+	}
+}
+
+func TestClientParallel(t *testing.T) {
+	receivers := []uint16{0x180, 0x301, 0x480}
+
+	// scan a small catalog
+	defer UseReadings(ElsterReadings)
+	UseReadings(ElsterReadings[:10])
+
+	for _, tc := range []struct {
+		parallel int
+		want     int // receivers with requests in flight at once
+	}{
+		{0, 3},
+		{1, 1},
+		{2, 2},
+	} {
+		tc := tc // read by handlers of the bus after the iteration
+		bus := NewVirtualBus(VirtualBusOptions{})
+
+		// responses are held until want receivers got a request, which
+		// fails the scan if fewer requests are sent in parallel
+		release := make(chan struct{})
+		var mu sync.Mutex
+		requested := make(map[uint16]bool)
+
+		bus.Connect().Subscribe(func(frm can.Frame) {
+			f, _ := ParseFrame(frm)
+			if f.Type != Read {
+				return
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if requested[f.Receiver] {
+				return
+			}
+			requested[f.Receiver] = true
+			if len(requested) == tc.want {
+				close(release)
+			}
+		})
+
+		for _, receiver := range receivers {
+			conn := bus.Connect()
+			res := &responder{t: conn, id: receiver, values: map[uint16][]byte{0x0001: {0x00, 0x01}}, reads: make(map[uint16]int)}
+			conn.Subscribe(func(frm can.Frame) {
+				go func() {
+					<-release
+					res.handle(frm)
+				}()
+			})
+		}
+
+		opts := DefaultClientOptions
+		opts.Quiet = true
+		opts.Timeout = 2 * time.Second
+		opts.Parallel = tc.parallel
+		client := NewClient(bus.Connect(), 0x680, opts)
+
+		var results []ScanResult
+		if err := client.ScanReceivers(context.Background(), receivers, func(res ScanResult) {
+			results = append(results, res)
+		}); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(results) != len(receivers) {
+			t.Errorf("Results incorrect, got: %v.", results)
+		}
+
+		// one request in flight per receiver, want receivers at once
+		inflight := make(map[uint16]bool)
+		max := 0
+		for _, rec := range bus.Traffic() {
+			f, _ := ParseFrame(rec.Frame)
+			switch f.Type {
+			case Read:
+				if inflight[f.Receiver] {
+					t.Errorf("Request to %X sent before response", f.Receiver)
+				}
+				inflight[f.Receiver] = true
+			case Response:
+				inflight[f.Sender] = false
+			}
+
+			n := 0
+			for _, ok := range inflight {
+				if ok {
+					n++
+				}
+			}
+			if n > max {
+				max = n
+			}
+		}
+
+		if max != tc.want {
+			t.Errorf("Requests in flight with parallel %d incorrect, got: %d, want: %d.", tc.parallel, max, tc.want)
+		}
+
+		client.Close()
+		bus.Close()
+	}
+}
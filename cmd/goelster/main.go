@@ -1,12 +1,17 @@
 package main
 
 import (
+	"context"
+	"encoding/binary"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"os/signal"
 	"strconv"
 	"strings"
+	"text/tabwriter"
+	"time"
 
 	"github.com/brutella/can"
 	"github.com/urfave/cli"
@@ -21,17 +26,27 @@ const (
 	scan
 	read
 	write
+	writeNumeric
 )
 
 var command Command
 var device string
 var sender uint16
 var receiver uint16
+var receivers []uint16
 var register uint16
 var value uint16
-var numeric float64
+var numeric interface{}
+var options ClientOptions
 
 func main() {
+	if err := newApp().Run(os.Args); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// newApp creates the command line application
+func newApp() *cli.App {
 	app := cli.NewApp()
 	app.HideVersion = true
 	app.Name = "goelster"
@@ -57,15 +72,33 @@ AUTHOR{{with $length := len .Authors}}{{if ne 1 $length}}S{{end}}{{end}}:
 
 OPTIONS:
    {{range $index, $option := .VisibleFlags}}{{if $index}}
-   {{end}}{{$option}}{{end}}{{end}}
+   {{end}}{{$option}}{{end}}{{end}}{{if .VisibleCommands}}
+
+COMMANDS:{{range .VisibleCategories}}{{if .Name}}
+   {{.Name}}:{{end}}{{range .VisibleCommands}}
+     {{join .Names ", "}}{{"\t"}}{{.Usage}}{{end}}{{end}}{{end}}
 
 EXAMPLES:
 
 	dump traffic:    goelster slcan0
 	scan device:     goelster slcan0 680 180
+	scan devices:    goelster slcan0 680 180,301,480,500,601
+	find devices:    goelster discover slcan0
+	serial adapter:  goelster slcan:///dev/ttyACM0 680 180
+	remote gateway:  goelster socketcand://pi:29536/can0 680 180
 	read register:   goelster slcan0 680 180.0013
+	read by name:    goelster slcan0 680 180.EINSTELL_SPEICHERSOLLTEMP
 	write register:  goelster slcan0 680 180.0013.01a4
-	numeric write:   goelster slcan0 680 180.0013 42.1 (NOT IMPLEMENTED YET)
+	numeric write:   goelster slcan0 680 180.0013 42.1
+	write by name:   goelster slcan0 680 480.PROGRAMMSCHALTER Automatik
+	register file:   goelster --registers wpm3.yaml slcan0 680 180.0013
+	json output:     goelster --output json slcan0 | jq .value
+	slow device:     goelster --timeout 300ms --retries 2 --gap 20ms slcan0 680 301
+	export table:    goelster registers export --format csv
+	import table:    goelster registers import -o registers.json KElsterTable.inc
+	simulate device: goelster simulate --id 180 --profile wpm3.json vcan0
+	record traffic:  goelster record -o capture.log slcan0
+	replay capture:  goelster "replay://capture.log?speed=0"
 {{if .Copyright}}
 COPYRIGHT:
    {{.Copyright}}{{end}}
@@ -73,26 +106,201 @@ COPYRIGHT:
 	cli.CommandHelpTemplate = cli.AppHelpTemplate
 	cli.SubcommandHelpTemplate = cli.AppHelpTemplate
 
-	app.UsageText = `goelster [options] [can device] [sender id] [receiver id][.register][.raw value] [numeric value]`
+	app.UsageText = `goelster [options] [can device] [sender id] [receiver id][.register index or name][.raw value] [value]`
 
 	app.Flags = []cli.Flag{
 		cli.BoolFlag{
 			Name:  "verbose, v",
 			Usage: "verbose mode",
 		},
+		cli.StringFlag{
+			Name:  "registers",
+			Usage: "merge register definitions from JSON, YAML or CSV `FILE`",
+		},
+		cli.BoolFlag{
+			Name:  "quiet, q",
+			Usage: "do not log request timing and retries",
+		},
+		cli.DurationFlag{
+			Name:  "timeout",
+			Value: DefaultClientOptions.Timeout,
+			Usage: "time to wait for a response",
+		},
+		cli.IntFlag{
+			Name:  "retries",
+			Usage: "number of retries if a device does not respond",
+		},
+		cli.DurationFlag{
+			Name:  "backoff",
+			Value: DefaultClientOptions.Backoff,
+			Usage: "wait before the first retry, doubled for each further retry",
+		},
+		cli.DurationFlag{
+			Name:  "gap",
+			Usage: "minimum time between two requests",
+		},
+		cli.IntFlag{
+			Name:  "parallel",
+			Value: DefaultClientOptions.Parallel,
+			Usage: "maximum number of receivers scanned in parallel, 0 is unlimited",
+		},
+		cli.StringFlag{
+			Name:  "output",
+			Value: OutputText,
+			Usage: "output format of dump, scan and read (text, json, csv)",
+		},
+	}
+
+	app.Before = func(c *cli.Context) error {
+		if file := c.String("registers"); file != "" {
+			readings, err := LoadReadings(file)
+			if err != nil {
+				return err
+			}
+			UseReadings(MergeReadings(ElsterReadings, readings))
+
+			if errs := DefaultCatalog.Check(); len(errs) > 0 {
+				for _, err := range errs {
+					log.Println(err)
+				}
+				return fmt.Errorf("%s: conflicting register definitions", file)
+			}
+		}
+
+		options = ClientOptions{
+			Timeout:  c.Duration("timeout"),
+			Retries:  c.Int("retries"),
+			Backoff:  c.Duration("backoff"),
+			Gap:      c.Duration("gap"),
+			Parallel: c.Int("parallel"),
+			Quiet:    c.Bool("quiet"),
+		}
+
+		if format := c.String("output"); format != OutputText {
+			w, err := NewRecordWriter(os.Stdout, format)
+			if err != nil {
+				return err
+			}
+			Output = w
+		}
+
+		return nil
+	}
+
+	app.Commands = []cli.Command{
+		{
+			Name:      "simulate",
+			Usage:     "simulate a device answering register reads and writes",
+			ArgsUsage: "DEVICE",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "id",
+					Value: "180",
+					Usage: "hex CAN id of the simulated device",
+				},
+				cli.StringFlag{
+					Name:  "profile",
+					Usage: "load register values and signals from JSON or YAML `FILE`",
+				},
+			},
+			Action: func(c *cli.Context) error {
+				if c.NArg() != 1 {
+					return cli.ShowCommandHelp(c, "simulate")
+				}
+				return simulate(c.Args().First(), c.String("id"), c.String("profile"), c.GlobalBool("verbose"))
+			},
+		},
+		{
+			Name:      "discover",
+			Usage:     "find devices by probing known ids and listening to traffic",
+			ArgsUsage: "DEVICE",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "sender",
+					Value: "680",
+					Usage: "hex CAN id used for requests",
+				},
+				cli.DurationFlag{
+					Name:  "listen",
+					Value: 5 * time.Second,
+					Usage: "minimum time to listen to traffic",
+				},
+			},
+			Action: func(c *cli.Context) error {
+				if c.NArg() != 1 {
+					return cli.ShowCommandHelp(c, "discover")
+				}
+				return discover(c.Args().First(), c.String("sender"), c.Duration("listen"))
+			},
+		},
+		{
+			Name:      "record",
+			Usage:     "record bus traffic in candump log format",
+			ArgsUsage: "DEVICE",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "output, o",
+					Usage: "write log to `FILE` instead of stdout",
+				},
+			},
+			Action: func(c *cli.Context) error {
+				if c.NArg() != 1 {
+					return cli.ShowCommandHelp(c, "record")
+				}
+				return record(c.Args().First(), c.String("output"))
+			},
+		},
+		{
+			Name:  "registers",
+			Usage: "manage register definitions",
+			Subcommands: []cli.Command{
+				{
+					Name:  "export",
+					Usage: "export register definitions",
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:  "format",
+							Value: FormatJSON,
+							Usage: "output format (json, yaml, csv)",
+						},
+					},
+					Action: func(c *cli.Context) error {
+						return WriteReadings(os.Stdout, ElsterReadings, c.String("format"))
+					},
+				},
+				{
+					Name:      "import",
+					Usage:     "import register definitions from can_progs KElsterTable.inc",
+					ArgsUsage: "FILE",
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:  "output, o",
+							Usage: "write imported definitions to JSON, YAML or CSV `FILE`",
+						},
+					},
+					Action: func(c *cli.Context) error {
+						if c.NArg() != 1 {
+							return cli.ShowCommandHelp(c, "import")
+						}
+						return importRegisters(c.Args().First(), c.String("output"))
+					},
+				},
+			},
+		},
 	}
 
 	app.Action = func(c *cli.Context) error {
-		if c.NArg() < 1 || c.NArg() > 3 {
+		if c.NArg() < 1 || c.NArg() > 4 {
 			cli.ShowCommandHelp(c, "")
 			return nil
 		}
 
 		command = dump
 		device = c.Args().Get(0)
+		receivers = nil
 
 		if c.NArg() > 1 {
-			if c.NArg() != 3 {
+			if c.NArg() < 3 {
 				cli.ShowCommandHelp(c, "")
 				return nil
 			}
@@ -114,22 +322,29 @@ COPYRIGHT:
 				return nil
 			}
 
-			rcvr, err := strconv.ParseUint(a[0], 16, 16)
-			if err != nil {
-				fmt.Printf("Could not parse hex receiver id '%s'", a[0])
+			for _, id := range strings.Split(a[0], ",") {
+				rcvr, err := strconv.ParseUint(id, 16, 16)
+				if err != nil {
+					fmt.Printf("Could not parse hex receiver id '%s'", id)
+					return nil
+				}
+				receivers = append(receivers, uint16(rcvr))
+			}
+			receiver = receivers[0]
+
+			if len(a) > 1 && len(receivers) > 1 {
+				cli.ShowCommandHelp(c, "")
 				return nil
-			} else {
-				receiver = uint16(rcvr)
 			}
 
 			if len(a) > 1 {
 				command = read
-				reg, err := strconv.ParseUint(a[1], 16, 16)
+				r, err := DefaultCatalog.Lookup(a[1])
 				if err != nil {
-					fmt.Printf("Could not parse hex register id '%s'", a[1])
+					fmt.Printf("Could not find register: %v", err)
 					return nil
 				} else {
-					register = uint16(reg)
+					register = r.Index
 				}
 			}
 
@@ -143,41 +358,252 @@ COPYRIGHT:
 					value = uint16(val)
 				}
 			}
+
+			if c.NArg() > 3 {
+				if len(a) != 2 {
+					cli.ShowCommandHelp(c, "")
+					return nil
+				}
+
+				command = writeNumeric
+				numeric = ParseValue(c.Args().Get(3), Reading(register))
+			}
 		}
 
-		bus, err := can.NewBusForInterfaceWithName(device)
+		bus, ctx, stop, err := openBus(device)
 		if err != nil {
-			log.Fatal(err)
+			return err
 		}
+		defer stop()
 
-		quit := make(chan os.Signal)
-		signal.Notify(quit, os.Interrupt)
-		signal.Notify(quit, os.Kill)
-
-		go func() {
-			select {
-			case <-quit:
-				bus.Disconnect()
-				os.Exit(1)
-			}
-		}()
+		client := NewClient(bus, sender, options)
 
 		switch command {
 		case dump:
 			CanDump(bus)
 		case scan:
-			CanScan(bus, sender, receiver)
+			return client.ScanReceivers(ctx, receivers, func(res ScanResult) {
+				printValue(res.Receiver, res.Reading, res.Value, res.Frame)
+			})
 		case read:
-			CanRead(bus, sender, receiver, register)
+			return readValue(ctx, client)
 		case write:
-			CanWrite(bus, sender, receiver, register, value)
+			payload := make([]byte, 2)
+			binary.BigEndian.PutUint16(payload, value)
+			val, err := client.WriteRaw(ctx, receiver, register, payload)
+			if err == nil {
+				fmt.Println(val)
+			}
+			return err
+		case writeNumeric:
+			val, err := client.Write(ctx, receiver, register, numeric)
+			if err == nil {
+				fmt.Println(val)
+			}
+			return err
 		}
 
 		return nil
 	}
 
-	err := app.Run(os.Args)
+	return app
+}
+
+// openBus connects to the device. The returned context is cancelled on
+// interrupt or by calling stop, which closes the bus.
+func openBus(device string) (Transport, context.Context, context.CancelFunc, error) {
+	bus, err := OpenTransport(device)
 	if err != nil {
-		log.Fatal(err)
+		return nil, nil, nil, err
 	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+
+	go func() {
+		<-ctx.Done()
+		bus.Close()
+	}()
+
+	return bus, ctx, stop, nil
+}
+
+// printValue prints a register value of the receiver. Frame is the response
+// or nil for values combined from several registers.
+func printValue(receiver uint16, r *ElsterReading, val Value, frm *can.Frame) {
+	switch {
+	case Output != nil && frm != nil:
+		Output.Write(NewFrameRecord(time.Now(), *frm))
+	case Output != nil:
+		Output.Write(NewValueRecord(time.Now(), receiver, sender, r, val))
+	case RawLog && frm != nil:
+		LogFrame(*frm)
+	case len(receivers) > 1:
+		fmt.Printf("%x ", receiver)
+		LogRegisterValue(val, r)
+	default:
+		LogRegisterValue(val, r)
+	}
+}
+
+// readValue reads and prints the register
+func readValue(ctx context.Context, client *Client) error {
+	if RawLog && Output == nil {
+		frm, err := client.ReadFrame(ctx, receiver, register)
+		if err == nil {
+			LogFrame(frm)
+		}
+		return err
+	}
+
+	val, err := client.Read(ctx, receiver, register)
+	if err != nil {
+		return err
+	}
+
+	if Output != nil {
+		Output.Write(NewValueRecord(time.Now(), receiver, sender, Reading(register), val))
+	} else {
+		fmt.Println(val)
+	}
+	return nil
+}
+
+// discover lists the devices found on the bus
+func discover(device string, id string, listen time.Duration) error {
+	s, err := strconv.ParseUint(id, 16, 16)
+	if err != nil {
+		return fmt.Errorf("could not parse hex sender id '%s'", id)
+	}
+
+	bus, ctx, stop, err := openBus(device)
+	if err != nil {
+		return err
+	}
+	defer stop()
+
+	client := NewClient(bus, uint16(s), options)
+	devices, err := client.Discover(ctx, listen)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tFAMILY\tSOFTWARE\tVERSION\tSTATUS")
+
+	for _, d := range devices {
+		status := "responding"
+		if !d.Responding {
+			status = "passive"
+		}
+
+		fmt.Fprintf(w, "%x\t%s\t%s\t%s\t%s\n", d.Id, d.Family, optional(d.SoftwareNumber), optional(d.SoftwareVersion), status)
+	}
+	w.Flush()
+
+	return err
+}
+
+// optional formats v or - if nil
+func optional(v *uint16) string {
+	if v == nil {
+		return "-"
+	}
+	return strconv.Itoa(int(*v))
+}
+
+// simulate answers requests to the simulated device until interrupted
+func simulate(device string, id string, file string, verbose bool) error {
+	i, err := strconv.ParseUint(id, 16, 16)
+	if err != nil {
+		return fmt.Errorf("could not parse hex device id '%s'", id)
+	}
+
+	var profile SimulatorProfile
+	if file != "" {
+		if profile, err = LoadSimulatorProfile(file); err != nil {
+			return err
+		}
+	}
+
+	sim, err := NewSimulator(uint16(i), profile)
+	if err != nil {
+		return err
+	}
+
+	bus, _, stop, err := openBus(device)
+	if err != nil {
+		return err
+	}
+	defer stop()
+
+	if verbose {
+		RawLog = true
+		bus.Subscribe(LogFrame)
+	}
+
+	sim.Run(bus)
+
+	return nil
+}
+
+// record logs bus traffic until interrupted
+func record(device string, output string) error {
+	var w io.Writer = os.Stdout
+	if output != "" {
+		f, err := os.Create(output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	// candump logs name the interface, gateways are logged as can0
+	iface := device
+	if strings.Contains(device, "://") {
+		iface = "can0"
+	}
+
+	bus, _, stop, err := openBus(device)
+	if err != nil {
+		return err
+	}
+	defer stop()
+
+	return Record(bus, NewCandumpWriter(w, iface))
+}
+
+// importRegisters imports the can_progs register table and reports the
+// differences to the current definitions
+func importRegisters(file string, output string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	readings, err := ImportElsterTable(f)
+	if err != nil {
+		return fmt.Errorf("%s: %v", file, err)
+	}
+
+	DiffReadings(ElsterReadings, readings).Report(os.Stdout)
+
+	if output == "" {
+		return nil
+	}
+
+	format, err := FormatForPath(output)
+	if err != nil {
+		return err
+	}
+
+	out, err := os.Create(output)
+	if err != nil {
+		return err
+	}
+
+	if err := WriteReadings(out, readings, format); err != nil {
+		out.Close()
+		return err
+	}
+
+	return out.Close()
 }
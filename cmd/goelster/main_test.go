@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bytes"
+	"net"
+	"testing"
+
+	"github.com/brutella/can"
+
+	. "github.com/andig/goelster"
+)
+
+// freeAddr returns a local UDP address not in use
+func freeAddr(t *testing.T) string {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	return conn.LocalAddr().String()
+}
+
+func TestWriteDate(t *testing.T) {
+	simAddr, appAddr := freeAddr(t), freeAddr(t)
+
+	sim, err := NewSimulator(0x180, SimulatorProfile{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bus, err := OpenCannelloni(appAddr, simAddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bus.Close()
+
+	// answer synchronously, Run could subscribe after the write was sent
+	bus.Subscribe(func(frm can.Frame) {
+		if f, err := ParseFrame(frm); err == nil {
+			if res, ok := sim.Handle(f); ok {
+				frm, _ := res.Marshal()
+				bus.Publish(frm)
+			}
+		}
+	})
+
+	args := []string{"goelster", "-q", "cannelloni://" + simAddr + "?local=" + appAddr, "680", "180.DATUM", "24.12"}
+	if err := newApp().Run(args); err != nil {
+		t.Fatal(err)
+	}
+
+	if b := sim.Value(0x000a); !bytes.Equal(b, []byte{0x18, 0x0C}) {
+		t.Errorf("Date incorrect, got: % X, want: 18 0C.", b)
+	}
+}
@@ -0,0 +1,454 @@
+package goelster
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// PayloadError is returned when a payload cannot be decoded as the requested type.
+type PayloadError struct {
+	Type    ElsterType
+	Payload []byte
+}
+
+func (e *PayloadError) Error() string {
+	return fmt.Sprintf("invalid payload % X for type %d", e.Payload, e.Type)
+}
+
+// UnsupportedTypeError is returned when a value cannot be encoded as the requested type,
+// either because the type cannot be encoded at all or because the value has the wrong Go type.
+type UnsupportedTypeError struct {
+	Type  ElsterType
+	Value interface{}
+}
+
+func (e *UnsupportedTypeError) Error() string {
+	return fmt.Sprintf("cannot encode %T value as type %d", e.Value, e.Type)
+}
+
+// RangeError is returned when a value exceeds the range of the requested type.
+type RangeError struct {
+	Type  ElsterType
+	Value interface{}
+}
+
+func (e *RangeError) Error() string {
+	return fmt.Sprintf("value %v out of range for type %d", e.Value, e.Type)
+}
+
+// FormatError is returned when a string cannot be parsed in the format of the requested type.
+type FormatError struct {
+	Type   ElsterType
+	Value  string
+	Format string
+}
+
+func (e *FormatError) Error() string {
+	return fmt.Sprintf("value '%s' does not match format %s for type %d", e.Value, e.Format, e.Type)
+}
+
+// noValue is the payload signalling that a register holds no value
+var noValue = []byte{0x80, 0x00}
+
+// Decode decodes the payload b according to the elster type t.
+// It returns a null value if the payload signals that the register holds no value.
+func Decode(b []byte, t ElsterType) (Value, error) {
+	val, err := decode(b, t)
+	if err != nil {
+		return Value{}, err
+	}
+
+	raw := make([]byte, len(b))
+	copy(raw, b)
+
+	return NewValue(val, t, raw), nil
+}
+
+func decode(b []byte, t ElsterType) (interface{}, error) {
+	if len(b) < 2 {
+		return nil, &PayloadError{t, b}
+	}
+
+	if bytes.Equal(b, noValue) {
+		return nil, nil
+	}
+
+	switch t {
+	case et_little_endian:
+		return float64(binary.LittleEndian.Uint16(b)), nil
+	case et_dec_val:
+		return float64(int16(binary.BigEndian.Uint16(b))) / 10, nil
+	case et_cent_val:
+		return float64(int16(binary.BigEndian.Uint16(b))) / 100, nil
+	case et_mil_val:
+		return float64(int16(binary.BigEndian.Uint16(b))) / 1000, nil
+	case et_double_val, et_triple_val:
+		// single part of an energy counter, see CombineEnergy
+		return float64(binary.BigEndian.Uint16(b)), nil
+
+	case et_byte:
+		return b[0], nil
+
+	case et_zeit:
+		val := binary.BigEndian.Uint16(b)
+		return fmt.Sprintf("%02d:%02d", byte(val&0xff), byte(val>>8)), nil
+	case et_datum:
+		val := binary.BigEndian.Uint16(b)
+		return fmt.Sprintf("%02d.%02d", byte(val>>8), byte(val&0xff)), nil
+	case et_time_domain:
+		val := binary.BigEndian.Uint16(b)
+		if val&0x8080 == 0 {
+			return fmt.Sprintf("%02d:%02d-%02d:%02d",
+				byte((val>>8)/4), byte(15*((val>>8)%4)),
+				byte((val&0xff)/4), byte(15*(val%4))), nil
+		}
+		return nil, nil
+
+	case et_betriebsart:
+		if name, ok := Betriebsarten.Name(enumInteger(b, t)); ok {
+			return name, nil
+		}
+
+	case et_dev_id:
+		return DeviceId(binary.BigEndian.Uint16(b)), nil
+	case et_dev_nr:
+		return DeviceNumber(binary.BigEndian.Uint16(b)), nil
+	case et_err_nr:
+		return ErrorText(binary.BigEndian.Uint16(b)), nil
+
+	case et_little_bool:
+		if bytes.Equal(b, []byte{0x01, 0x00}) {
+			return true, nil
+		} else if bytes.Equal(b, []byte{0x00, 0x00}) {
+			return false, nil
+		}
+		return nil, &PayloadError{t, b}
+
+	case et_bool:
+		if bytes.Equal(b, []byte{0x00, 0x01}) {
+			return true, nil
+		} else if bytes.Equal(b, []byte{0x00, 0x00}) {
+			return false, nil
+		}
+		return nil, &PayloadError{t, b}
+	}
+
+	// default
+	return b, nil
+}
+
+// Encode encodes val according to the elster type t. Numeric types accept any
+// Go integer or float value, boolean types accept bool or the numbers 0 and 1.
+// Times, dates and time domains are parsed from strings as formatted by Decode,
+// nil encodes an unused time domain. Raw two byte payloads are accepted for
+// untyped registers and Values are encoded using their decoded Go value.
+func Encode(val interface{}, t ElsterType) ([]byte, error) {
+	if v, ok := val.(Value); ok {
+		val = v.Interface()
+	}
+
+	b := []byte{0, 0}
+
+	switch t {
+	case none:
+		if raw, ok := val.([]byte); ok && len(raw) == 2 {
+			copy(b, raw)
+			return b, nil
+		}
+
+	case et_little_endian:
+		if f, ok := toFloat(val); ok {
+			u, err := integer(f, 1, 0, math.MaxUint16, t, val)
+			if err != nil {
+				return nil, err
+			}
+			binary.LittleEndian.PutUint16(b, uint16(u))
+			return b, nil
+		}
+	case et_dec_val, et_cent_val, et_mil_val:
+		if f, ok := toFloat(val); ok {
+			// 0x8000 is reserved for signalling no value
+			i, err := integer(f, scale(t), math.MinInt16+1, math.MaxInt16, t, val)
+			if err != nil {
+				return nil, err
+			}
+			binary.BigEndian.PutUint16(b, uint16(int16(i)))
+			return b, nil
+		}
+
+	case et_byte:
+		if f, ok := toFloat(val); ok {
+			u, err := integer(f, 1, 0, math.MaxUint8, t, val)
+			if err != nil {
+				return nil, err
+			}
+			b[0] = byte(u)
+			return b, nil
+		}
+
+	case et_zeit:
+		if s, ok := val.(string); ok {
+			hour, minute, err := parsePair(s, ":", "HH:MM", t)
+			if err != nil {
+				return nil, err
+			}
+			if hour > 23 || minute > 59 {
+				return nil, &RangeError{t, val}
+			}
+			b[0], b[1] = byte(minute), byte(hour)
+			return b, nil
+		}
+	case et_datum:
+		if s, ok := val.(string); ok {
+			day, month, err := parsePair(s, ".", "DD.MM", t)
+			if err != nil {
+				return nil, err
+			}
+			if day < 1 || day > 31 || month < 1 || month > 12 {
+				return nil, &RangeError{t, val}
+			}
+			b[0], b[1] = byte(day), byte(month)
+			return b, nil
+		}
+	case et_time_domain:
+		if val == nil {
+			// 0x80 marks start and end as unused
+			return []byte{0x80, 0x80}, nil
+		}
+		if s, ok := val.(string); ok {
+			from, to, found := strings.Cut(s, "-")
+			if !found {
+				return nil, &FormatError{t, s, "HH:MM-HH:MM"}
+			}
+			start, err := quarters(from, t)
+			if err != nil {
+				return nil, err
+			}
+			end, err := quarters(to, t)
+			if err != nil {
+				return nil, err
+			}
+			if start > end {
+				return nil, &RangeError{t, val}
+			}
+			b[0], b[1] = start, end
+			return b, nil
+		}
+
+	case et_betriebsart:
+		if name, ok := val.(string); ok {
+			v, known := Betriebsarten.Value(name)
+			if !known {
+				return nil, &RangeError{t, val}
+			}
+			val = v
+		}
+		if f, ok := toFloat(val); ok {
+			u, err := integer(f, 1, 0, math.MaxUint8, t, val)
+			if err != nil {
+				return nil, err
+			}
+			b[0] = byte(u)
+			return b, nil
+		}
+
+	case et_little_bool, et_bool:
+		set, ok := val.(bool)
+		if !ok {
+			f, isNum := toFloat(val)
+			if !isNum {
+				break
+			}
+			if f != 0 && f != 1 {
+				return nil, &RangeError{t, val}
+			}
+			set = f == 1
+		}
+
+		if set && t == et_little_bool {
+			b[0] = 0x01
+		} else if set {
+			b[1] = 0x01
+		}
+		return b, nil
+	}
+
+	return nil, &UnsupportedTypeError{t, val}
+}
+
+// parsePair parses two unsigned decimal numbers separated by sep
+func parsePair(s string, sep string, format string, t ElsterType) (int, int, error) {
+	a, b, found := strings.Cut(s, sep)
+	if !found || !isDigits(a) || !isDigits(b) {
+		return 0, 0, &FormatError{t, s, format}
+	}
+	x, errA := strconv.Atoi(a)
+	y, errB := strconv.Atoi(b)
+	if errA != nil || errB != nil {
+		return 0, 0, &FormatError{t, s, format}
+	}
+	return x, y, nil
+}
+
+// quarters converts HH:MM to the number of quarter hours since midnight.
+// The minutes must be a multiple of 15, 24:00 is the end of the day.
+func quarters(s string, t ElsterType) (byte, error) {
+	hour, minute, err := parsePair(s, ":", "HH:MM", t)
+	if err != nil {
+		return 0, err
+	}
+	q := 4*hour + minute/15
+	if minute%15 != 0 || minute > 59 || q > 4*24 {
+		return 0, &RangeError{t, s}
+	}
+	return byte(q), nil
+}
+
+// DecodeValue is like Decode but returns nil if the payload cannot be decoded.
+func DecodeValue(b []byte, t ElsterType) interface{} {
+	val, err := Decode(b, t)
+	if err != nil {
+		return nil
+	}
+	return val.Interface()
+}
+
+// isNumeric returns true for types decoded as numbers
+func isNumeric(t ElsterType) bool {
+	switch t {
+	case et_dec_val, et_cent_val, et_mil_val, et_little_endian, et_byte, et_double_val, et_triple_val:
+		return true
+	}
+	return false
+}
+
+// scale returns the factor between decoded value and payload integer of the scaled types
+func scale(t ElsterType) float64 {
+	switch t {
+	case et_dec_val:
+		return 10
+	case et_cent_val:
+		return 100
+	case et_mil_val:
+		return 1000
+	}
+	return 1
+}
+
+// integer rounds f*scale and checks that the result is within [min, max]
+func integer(f float64, scale float64, min float64, max float64, t ElsterType, val interface{}) (int64, error) {
+	i := math.Round(f * scale)
+	if i < min || i > max || math.IsNaN(i) {
+		return 0, &RangeError{t, val}
+	}
+	return int64(i), nil
+}
+
+// toFloat converts any Go integer or float value to float64
+func toFloat(val interface{}) (float64, bool) {
+	switch v := val.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int8:
+		return float64(v), true
+	case int16:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint:
+		return float64(v), true
+	case uint8:
+		return float64(v), true
+	case uint16:
+		return float64(v), true
+	case uint32:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	}
+	return 0, false
+}
+
+// DecodeReading decodes the payload of register r applying its scale and unit
+func DecodeReading(b []byte, r *ElsterReading) (Value, error) {
+	val, err := Decode(b, r.Type)
+	if err != nil {
+		return val, err
+	}
+
+	if r.Scale != 0 && (val.Kind == FloatValue || val.Kind == ByteValue) {
+		val = NewValue(val.Float()*r.Scale, r.Type, val.Raw)
+	}
+	val = decodeEnum(val, b, r)
+	val.Unit = r.Unit
+
+	return val, nil
+}
+
+// ParseValue converts the text s to a value for EncodeReading of register r.
+// Times, dates and time domains are kept as string, numbers are parsed as
+// float and other values like names of enum values are kept as string.
+func ParseValue(s string, r *ElsterReading) interface{} {
+	switch r.Type {
+	case et_zeit, et_datum, et_time_domain:
+		return s
+	}
+
+	if val, err := strconv.ParseFloat(s, 64); err == nil {
+		return val
+	}
+	return s
+}
+
+// EncodeReading encodes val for writing register r. Read-only registers and
+// values outside the register range are rejected.
+func EncodeReading(val interface{}, r *ElsterReading) ([]byte, error) {
+	if r.ReadOnly {
+		return nil, fmt.Errorf("register %s is read-only", r.Name)
+	}
+
+	if v, ok := val.(Value); ok {
+		val = v.Interface()
+	}
+
+	if f, ok := toFloat(val); ok {
+		if r.Min < r.Max && (f < r.Min || f > r.Max) {
+			return nil, fmt.Errorf("value %v out of range %v..%v for register %s", val, r.Min, r.Max, r.Name)
+		}
+		if r.Step != 0 {
+			if steps := (f - r.Min) / r.Step; math.Abs(steps-math.Round(steps)) > 1e-6 {
+				return nil, fmt.Errorf("value %v is not a multiple of %v for register %s", val, r.Step, r.Name)
+			}
+		}
+	}
+
+	return encodeReading(val, r)
+}
+
+// encodeReading converts val to the register payload without checking
+// access and range
+func encodeReading(val interface{}, r *ElsterReading) ([]byte, error) {
+	if v, ok := val.(Value); ok {
+		val = v.Interface()
+	}
+
+	if name, ok := val.(string); ok && len(r.Enum) > 0 {
+		return encodeEnum(name, r)
+	}
+
+	if f, ok := toFloat(val); ok && r.Scale != 0 {
+		val = f / r.Scale
+	}
+
+	return Encode(val, r.Type)
+}
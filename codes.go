@@ -0,0 +1,56 @@
+package goelster
+
+import "fmt"
+
+// ErrorList maps the error numbers of et_err_nr registers to the error texts
+// of Stiebel Eltron and Tecalor heat pumps as listed by can_progs
+var ErrorList = map[uint16]string{
+	0x0002: "Schuetz klebt",
+	0x0003: "ERR HD-SENSOR",
+	0x0004: "Hochdruck",
+	0x0005: "Verdampferfuehler",
+	0x0006: "Relaistreiber",
+	0x0007: "Relaispegel",
+	0x0008: "Hexschalter",
+	0x0009: "Drehzahl Luefter",
+	0x000a: "Lueftertreiber",
+	0x000b: "Reset Baustein",
+	0x000c: "ND",
+	0x000d: "ROM",
+	0x000e: "QUELLEN MINTEMP",
+	0x0010: "Abtauen",
+	0x0012: "ERR T-HEI IWS",
+	0x0017: "ERR T-FRO IWS",
+	0x001a: "Niederdruck",
+	0x001b: "ERR ND-DRUCK",
+	0x001c: "ERR HD-DRUCK",
+	0x001d: "HD-SENSOR-MAX",
+	0x001e: "HEISSGAS-MAX",
+	0x001f: "ERR HD-SENSOR",
+	0x0020: "Einfrierschutz",
+	0x0021: "KEINE LEISTUNG",
+}
+
+// DeviceId formats a device id as device type and software revision like
+// can_progs does. Type bytes are not resolved to names, no documented list
+// of them is available.
+func DeviceId(val uint16) string {
+	return fmt.Sprintf("%d-%d", val>>8, val&0xff)
+}
+
+// DeviceNumber formats a zero-based device number counting from 1.
+// Numbers of 0x80 and above signal that no device is set.
+func DeviceNumber(val uint16) string {
+	if val >= 0x80 {
+		return "--"
+	}
+	return fmt.Sprintf("%d", val+1)
+}
+
+// ErrorText returns the text of the error number or ERR and the number if unknown
+func ErrorText(val uint16) string {
+	if text, ok := ErrorList[val]; ok {
+		return text
+	}
+	return fmt.Sprintf("ERR %d", val)
+}
@@ -0,0 +1,74 @@
+package goelster
+
+import "testing"
+
+func TestDeviceId(t *testing.T) {
+	tests := []struct {
+		payload []byte
+		str     string
+	}{
+		{[]byte{0x61, 0x02}, "97-2"},
+		{[]byte{0x00, 0x00}, "0-0"},
+		{[]byte{0x80, 0x00}, "<nil>"},
+	}
+
+	for _, tc := range tests {
+		val, err := Decode(tc.payload, et_dev_id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if val.String() != tc.str {
+			t.Errorf("Device id % X incorrect, got: %s, want: %s.", tc.payload, val, tc.str)
+		}
+	}
+}
+
+func TestDeviceNumber(t *testing.T) {
+	tests := []struct {
+		payload []byte
+		str     string
+	}{
+		{[]byte{0x00, 0x00}, "1"},
+		{[]byte{0x00, 0x04}, "5"},
+		{[]byte{0x00, 0x7f}, "128"},
+		{[]byte{0x00, 0x80}, "--"},
+		{[]byte{0x00, 0xff}, "--"},
+		{[]byte{0x80, 0x00}, "<nil>"},
+	}
+
+	for _, tc := range tests {
+		val, err := Decode(tc.payload, et_dev_nr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if val.String() != tc.str {
+			t.Errorf("Device number % X incorrect, got: %s, want: %s.", tc.payload, val, tc.str)
+		}
+	}
+}
+
+func TestErrorText(t *testing.T) {
+	tests := []struct {
+		payload []byte
+		str     string
+	}{
+		{[]byte{0x00, 0x04}, "Hochdruck"},
+		{[]byte{0x00, 0x1a}, "Niederdruck"},
+		{[]byte{0x00, 0x21}, "KEINE LEISTUNG"},
+		{[]byte{0x00, 0x01}, "ERR 1"},
+		{[]byte{0x01, 0x00}, "ERR 256"},
+	}
+
+	for _, tc := range tests {
+		val, err := Decode(tc.payload, et_err_nr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if val.Kind != StringValue {
+			t.Errorf("Kind of % X incorrect, got: %d, want: %d.", tc.payload, val.Kind, StringValue)
+		}
+		if val.String() != tc.str {
+			t.Errorf("Error text % X incorrect, got: %s, want: %s.", tc.payload, val, tc.str)
+		}
+	}
+}
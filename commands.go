@@ -1,6 +1,8 @@
 package goelster
 
 import (
+	"context"
+	"encoding/binary"
 	"fmt"
 	"log"
 	"os"
@@ -9,116 +11,132 @@ import (
 	"github.com/brutella/can"
 )
 
-func CanDump(bus *can.Bus) {
-	bus.SubscribeFunc(LogFrame)
-	bus.ConnectAndPublish()
+func CanDump(t Transport) {
+	if Output != nil {
+		t.Subscribe(func(frm can.Frame) {
+			Output.Write(NewFrameRecord(time.Now(), frm))
+		})
+	} else {
+		t.Subscribe(LogFrame)
+	}
+	<-t.Done()
 }
 
-// makeScanMatcher creates a function that matches send/recv/reg for incoming frames
-func makeScanMatcher(
-	c chan can.Frame,
-	sender uint16,
-	receiver uint16,
-	register uint16,
-) func(frm can.Frame) {
-	return func(frm can.Frame) {
-		frmReceiver := ReceiverId(frm.Data[:2])
-
-		// frame sent back from receiver to sender?
-		if uint16(frm.ID) == receiver && frmReceiver == sender {
-			// requested register?
-			if reg, _ := Payload(frm.Data[:]); reg == register {
-				// data frame?
-				if frm.Data[0]&Data != 0 {
-					c <- frm
-				}
-			}
+func equalValues(a []uint16, b []uint16) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return false
 		}
 	}
+	return true
 }
 
-// createReadFrame constructs a CAN bus request frame
-func createReadFrame(sender uint16, receiver uint16, r *ElsterReading) *can.Frame {
-	frm := can.Frame{
-		ID:     uint32(sender),
-		Length: 8,
-		Data:   [8]uint8{},
-	}
-	copy(frm.Data[:], RequestFrame(receiver, r))
-	return &frm
+func isEnergy(r *ElsterReading) bool {
+	return r.Type == et_double_val || r.Type == et_triple_val
 }
 
-func readRegister(
-	bus *can.Bus,
-	sender uint16,
-	receiver uint16,
-	r *ElsterReading,
-) *can.Frame {
-	c := make(chan can.Frame) // signalling channel
-	frm := createReadFrame(sender, receiver, r)
-
-	handler := can.NewHandler(makeScanMatcher(c, sender, receiver, r.Index))
-	bus.Subscribe(handler)
-	defer bus.Unsubscribe(handler)
-
-	startTime := time.Now()
-	bus.Publish(*frm)
-	select {
-	case <-time.After(100 * time.Millisecond):
-		// timeout
-		return nil
-	case frm := <-c:
-		// result
-		duration := time.Since(startTime)
-		log.Printf("CAN read took: %.fms", duration.Seconds()*1e3)
-		return &frm
+// logScanResult writes the scan result to Output or the log
+func logScanResult(sender uint16, res ScanResult) {
+	switch {
+	case Output != nil && res.Frame != nil:
+		Output.Write(NewFrameRecord(time.Now(), *res.Frame))
+	case Output != nil:
+		Output.Write(NewValueRecord(time.Now(), res.Receiver, sender, res.Reading, res.Value))
+	case RawLog && res.Frame != nil:
+		LogFrame(*res.Frame)
+	default:
+		LogRegisterValue(res.Value, res.Reading)
 	}
 }
 
-func CanScan(bus *can.Bus, sender uint16, receiver uint16) {
-	go bus.ConnectAndPublish()
-	defer bus.Disconnect()
-
-	for _, r := range ElsterReadings {
-		if frm := readRegister(bus, sender, receiver, r); frm != nil {
-			_, payload := Payload(frm.Data[:])
-			val := DecodeValue(payload, r.Type)
-
-			if val != nil {
-				if RawLog {
-					LogFrame(*frm)
-				} else {
-					LogRegisterValue(val, r)
-				}
-			}
-		}
-	}
+// CanScan reads all registers of the receiver and logs those holding a value
+//
+// Deprecated: use Client.Scan
+func CanScan(t Transport, sender uint16, receiver uint16) {
+	client := NewClient(t, sender, DefaultClientOptions)
+	defer client.Close()
+
+	client.Scan(context.Background(), receiver, func(res ScanResult) {
+		logScanResult(sender, res)
+	})
 }
 
-func CanRead(bus *can.Bus, sender uint16, receiver uint16, register uint16) {
+// CanRead reads the register and prints its value. It exits if the device
+// does not respond.
+//
+// Deprecated: use Client.Read or Client.ReadFrame
+func CanRead(t Transport, sender uint16, receiver uint16, register uint16) {
 	r := Reading(register)
 	if r == nil {
 		log.Fatalf("Unknown register '%X'", register)
 	}
 
-	go bus.ConnectAndPublish()
-	defer bus.Disconnect()
+	client := NewClient(t, sender, DefaultClientOptions)
+	defer client.Close()
 
-	frm := readRegister(bus, sender, receiver, r)
-	if frm == nil {
+	ctx := context.Background()
+
+	if isEnergy(r) {
+		val, err := client.Read(ctx, receiver, register)
+		if err != nil {
+			log.Println(err)
+			os.Exit(1)
+		}
+		logScanResult(sender, ScanResult{Receiver: receiver, Reading: r, Value: val})
+		return
+	}
+
+	frm, err := client.ReadFrame(ctx, receiver, register)
+	if err != nil {
+		log.Println(err)
 		os.Exit(1)
 	}
 
-	if RawLog {
-		LogFrame(*frm)
-	} else {
-		_, payload := Payload(frm.Data[:])
-		val := DecodeValue(payload, r.Type)
-		valStr := ValueString(val)
-		fmt.Println(valStr)
+	if Output != nil || RawLog {
+		logScanResult(sender, ScanResult{Receiver: receiver, Reading: r, Frame: &frm})
+		return
 	}
+
+	_, payload := Payload(frm.Data[:])
+	val, err := DecodeReading(payload, r)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(val)
+}
+
+// canWrite writes using the client and prints the value read back. It exits
+// if the write failed or was not confirmed.
+func canWrite(t Transport, sender uint16, write func(*Client) (Value, error)) {
+	client := NewClient(t, sender, DefaultClientOptions)
+	defer client.Close()
+
+	val, err := write(client)
+	if err != nil {
+		log.Println(err)
+		os.Exit(1)
+	}
+	fmt.Println(val)
+}
+
+// CanWrite writes the raw value to the register and verifies it by reading it back
+//
+// Deprecated: use Client.WriteRaw
+func CanWrite(t Transport, sender uint16, receiver uint16, register uint16, value uint16) {
+	payload := make([]byte, 2)
+	binary.BigEndian.PutUint16(payload, value)
+
+	canWrite(t, sender, func(c *Client) (Value, error) {
+		return c.WriteRaw(context.Background(), receiver, register, payload)
+	})
 }
 
-func CanWrite(bus *can.Bus, sender uint16, receiver uint16, register uint16, value uint16) {
-	log.Fatal("Not implemented")
+// CanWriteValue encodes the numeric value according to the register type,
+// writes it to the register and verifies it by reading it back
+//
+// Deprecated: use Client.Write
+func CanWriteValue(t Transport, sender uint16, receiver uint16, register uint16, value float64) {
+	canWrite(t, sender, func(c *Client) (Value, error) {
+		return c.Write(context.Background(), receiver, register, value)
+	})
 }
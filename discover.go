@@ -0,0 +1,175 @@
+package goelster
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/brutella/can"
+)
+
+// Registers probed during discovery
+const (
+	registerSoftwareNumber  = 0x0199 // SOFTWARE_NUMMER
+	registerSoftwareVersion = 0x019a // SOFTWARE_VERSION
+)
+
+// DeviceFamily is a range of CAN IDs used by a kind of device
+type DeviceFamily struct {
+	Name     string
+	From, To uint16
+}
+
+// DeviceFamilies lists the CAN IDs of known devices. Only IDs documented by
+// the sources of this package are listed: the heat pump 180 answering
+// ComfortSoft in the haustechnikdialog.de trace quoted in elster.go, and the
+// WPM 480 noted for ZWEITER_WE_STATUS_480 in the can_progs register table.
+// Other devices are found by listening to the bus.
+var DeviceFamilies = []DeviceFamily{
+	{"heat pump", 0x180, 0x180},
+	{"WPM", 0x480, 0x480},
+}
+
+// Family returns the name of the device family of the CAN ID
+func Family(id uint16) string {
+	for _, f := range DeviceFamilies {
+		if id >= f.From && id <= f.To {
+			return f.Name
+		}
+	}
+	return "unknown"
+}
+
+// Device is a node found on the bus
+type Device struct {
+	Id              uint16
+	Family          string
+	SoftwareNumber  *uint16 // nil if not available
+	SoftwareVersion *uint16 // nil if not available
+	Responding      bool    // device answered requests
+	Seen            bool    // device sent telegrams to others
+}
+
+// Discover probes all CAN IDs of the known device families and listens to
+// bus traffic for at least the listen duration. Devices seen in traffic are
+// probed as well, until the listen duration is over and all probes have
+// finished.
+func (c *Client) Discover(ctx context.Context, listen time.Duration) ([]Device, error) {
+	var mu sync.Mutex
+	devices := make(map[uint16]*Device)
+
+	device := func(id uint16) *Device {
+		d, ok := devices[id]
+		if !ok {
+			d = &Device{Id: id, Family: Family(id)}
+			devices[id] = d
+		}
+		return d
+	}
+
+	probed := make(map[uint16]bool)
+	listening := true
+	running := 0
+	idle := make(chan struct{})
+
+	// probe reads the software number and version of the device
+	probe := func(id uint16) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if !listening && running == 0 || probed[id] || id&^receiverMask != 0 {
+			return
+		}
+		probed[id] = true
+		running++
+
+		go func() {
+			number, ok := c.readSoftware(ctx, id, registerSoftwareNumber)
+			var version *uint16
+			if ok {
+				version, _ = c.readSoftware(ctx, id, registerSoftwareVersion)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			if ok {
+				d := device(id)
+				d.Responding = true
+				d.SoftwareNumber = number
+				d.SoftwareVersion = version
+			}
+
+			running--
+			if !listening && running == 0 {
+				close(idle)
+			}
+		}()
+	}
+
+	unsubscribe := c.t.Subscribe(func(frm can.Frame) {
+		// responses to the probes are not counted as traffic
+		f, err := ParseFrame(frm)
+		if err != nil || f.Sender == c.Sender || f.Receiver == c.Sender {
+			return
+		}
+
+		mu.Lock()
+		device(f.Sender).Seen = true
+		mu.Unlock()
+
+		probe(f.Sender)
+	})
+	defer unsubscribe()
+
+	for _, f := range DeviceFamilies {
+		for id := f.From; id <= f.To; id++ {
+			probe(id)
+		}
+	}
+
+	// cancellation is reported once the started probes have finished
+	sleep(ctx, listen)
+
+	mu.Lock()
+	listening = false
+	if running == 0 {
+		close(idle)
+	}
+	mu.Unlock()
+
+	<-idle
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	var res []Device
+	for _, d := range devices {
+		res = append(res, *d)
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Id < res[j].Id
+	})
+
+	return res, ctx.Err()
+}
+
+// readSoftware reads a software register. It returns false if the device did
+// not respond and nil if the register holds no value.
+func (c *Client) readSoftware(ctx context.Context, id uint16, register uint16) (*uint16, bool) {
+	payload, err := c.readPayload(ctx, id, register)
+	if err != nil || len(payload) != 2 {
+		return nil, false
+	}
+
+	if bytes.Equal(payload, noValue) {
+		return nil, true
+	}
+
+	val := binary.BigEndian.Uint16(payload)
+	return &val, true
+}
@@ -0,0 +1,116 @@
+package goelster
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/brutella/can"
+)
+
+func TestFamily(t *testing.T) {
+	tests := map[uint16]string{
+		0x180: "heat pump",
+		0x301: "unknown",
+		0x480: "WPM",
+		0x601: "unknown",
+		0x680: "unknown",
+	}
+
+	for id, want := range tests {
+		if got := Family(id); got != want {
+			t.Errorf("Family(%x) incorrect, got: %s, want: %s.", id, got, want)
+		}
+	}
+}
+
+// serve answers requests to the simulator. Unlike Run it subscribes before
+// returning, so no probe is missed.
+func serve(conn Transport, sim *Simulator) {
+	conn.Subscribe(func(frm can.Frame) {
+		if f, err := ParseFrame(frm); err == nil {
+			if res, ok := sim.Handle(f); ok {
+				frm, _ := res.Marshal()
+				conn.Publish(frm)
+			}
+		}
+	})
+}
+
+func TestDiscover(t *testing.T) {
+	bus := NewVirtualBus(VirtualBusOptions{})
+	defer bus.Close()
+
+	for id, profile := range map[uint16]SimulatorProfile{
+		0x480: {Raw: map[string]string{"SOFTWARE_NUMMER": "00cc"}},
+		0x700: {},
+	} {
+		sim, err := NewSimulator(id, profile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		serve(bus.Connect(), sim)
+	}
+
+	// 700 is not probed. Its conversation with 180 is sent
+	// before 180 answers the first probe, so it is received while probing.
+	heatPump, err := NewSimulator(0x180, SimulatorProfile{Raw: map[string]string{"SOFTWARE_NUMMER": "0140", "SOFTWARE_VERSION": "000c"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn := bus.Connect()
+	var once sync.Once
+	conn.Subscribe(func(frm can.Frame) {
+		f, err := ParseFrame(frm)
+		if err != nil {
+			return
+		}
+		res, ok := heatPump.Handle(f)
+		if !ok {
+			return
+		}
+
+		once.Do(func() {
+			for _, f := range []Frame{
+				{Sender: 0x700, Receiver: 0x180, Type: Read, Register: 0x000c},
+				{Sender: 0x180, Receiver: 0x700, Type: Response, Register: 0x000c, Payload: []byte{0x00, 0xE1}},
+			} {
+				frm, _ := f.Marshal()
+				conn.Publish(frm)
+			}
+		})
+
+		frm, _ = res.Marshal()
+		conn.Publish(frm)
+	})
+
+	client := newTestClient(bus.Connect())
+	client.Options.Timeout = 200 * time.Millisecond
+
+	devices, err := client.Discover(context.Background(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(devices) != 3 {
+		t.Fatalf("Devices incorrect, got: %+v.", devices)
+	}
+
+	d := devices[0]
+	if d.Id != 0x180 || d.Family != "heat pump" || !d.Responding || !d.Seen ||
+		d.SoftwareNumber == nil || *d.SoftwareNumber != 320 || d.SoftwareVersion == nil || *d.SoftwareVersion != 12 {
+		t.Errorf("Device incorrect, got: %+v.", d)
+	}
+
+	d = devices[1]
+	if d.Id != 0x480 || d.Family != "WPM" || !d.Responding || d.SoftwareNumber == nil || *d.SoftwareNumber != 204 || d.SoftwareVersion != nil {
+		t.Errorf("Device incorrect, got: %+v.", d)
+	}
+
+	d = devices[2]
+	if d.Id != 0x700 || d.Family != "unknown" || !d.Responding || !d.Seen || d.SoftwareNumber != nil {
+		t.Errorf("Device incorrect, got: %+v.", d)
+	}
+}
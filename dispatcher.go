@@ -0,0 +1,114 @@
+package goelster
+
+import (
+	"context"
+	"sync"
+
+	"github.com/brutella/can"
+)
+
+// requestKey identifies a pending request by the addressed device and register
+type requestKey struct {
+	receiver uint16
+	register uint16
+}
+
+// dispatcher correlates responses received through a single subscription
+// with pending requests. It allows one request in flight per receiver and
+// limits the number of receivers with requests in flight.
+type dispatcher struct {
+	sender      uint16
+	mu          sync.Mutex
+	pending     map[requestKey]chan can.Frame
+	receivers   map[uint16]chan struct{}
+	parallel    chan struct{} // nil if unlimited
+	unsubscribe func()
+}
+
+// newDispatcher subscribes to responses sent to sender. Parallel limits the
+// number of receivers with requests in flight, 0 is unlimited.
+func newDispatcher(t Transport, sender uint16, parallel int) *dispatcher {
+	d := &dispatcher{
+		sender:    sender,
+		pending:   make(map[requestKey]chan can.Frame),
+		receivers: make(map[uint16]chan struct{}),
+	}
+
+	if parallel > 0 {
+		d.parallel = make(chan struct{}, parallel)
+	}
+
+	d.unsubscribe = t.Subscribe(d.handle)
+
+	return d
+}
+
+// handle passes responses to the pending request
+func (d *dispatcher) handle(frm can.Frame) {
+	f, err := ParseFrame(frm)
+	if err != nil || f.Type != Response || f.Receiver != d.sender {
+		return
+	}
+
+	d.mu.Lock()
+	c, ok := d.pending[requestKey{f.Sender, f.Register}]
+	d.mu.Unlock()
+
+	if ok {
+		select {
+		case c <- frm:
+		default:
+			// response already received
+		}
+	}
+}
+
+// acquire waits until no other request to the receiver is in flight and the
+// number of parallel requests allows another one
+func (d *dispatcher) acquire(ctx context.Context, receiver uint16) (release func(), err error) {
+	d.mu.Lock()
+	slot, ok := d.receivers[receiver]
+	if !ok {
+		slot = make(chan struct{}, 1)
+		d.receivers[receiver] = slot
+	}
+	d.mu.Unlock()
+
+	select {
+	case slot <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	if d.parallel != nil {
+		select {
+		case d.parallel <- struct{}{}:
+		case <-ctx.Done():
+			<-slot
+			return nil, ctx.Err()
+		}
+	}
+
+	return func() {
+		if d.parallel != nil {
+			<-d.parallel
+		}
+		<-slot
+	}, nil
+}
+
+// expect registers a request and returns the channel receiving its response
+func (d *dispatcher) expect(receiver uint16, register uint16) (chan can.Frame, func()) {
+	key := requestKey{receiver, register}
+	c := make(chan can.Frame, 1)
+
+	d.mu.Lock()
+	d.pending[key] = c
+	d.mu.Unlock()
+
+	return c, func() {
+		d.mu.Lock()
+		delete(d.pending, key)
+		d.mu.Unlock()
+	}
+}
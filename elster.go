@@ -1,10 +1,8 @@
 package goelster
 
 import (
-	"bytes"
 	"encoding/binary"
 	"fmt"
-	"log"
 )
 
 /*
@@ -28,15 +26,57 @@ import (
                   Partner ID: 0x30 * 8 + 0x00 = 0x180
        Responses: 2nd digit is 2
                   Partner ID: 0xd0 * 8 + 0x00 = 0x680
+       Types:     0 write, 1 read, 2 response, 3 ack,
+                  4 write ack, 5 write response, 6 system
    4) 0xFA indicates that the Elster index is greater than ff.
    5) Index (parameter) queried for: 0930 for kWh and 0931 for MWh
    6) Value returned 27h=39,73h=115
 */
 
+// MessageType is the type of message encoded in the low nibble of byte 0
+type MessageType byte
+
 const (
 	// byte 0
-	Request byte = 0x01
-	Data    byte = 0x02
+	Write         MessageType = 0x00
+	Read          MessageType = 0x01
+	Response      MessageType = 0x02
+	Ack           MessageType = 0x03
+	WriteAck      MessageType = 0x04
+	WriteResponse MessageType = 0x05
+	System        MessageType = 0x06
+)
+
+var messageTypes = map[MessageType]string{
+	Write:         "write",
+	Read:          "read",
+	Response:      "response",
+	Ack:           "ack",
+	WriteAck:      "write-ack",
+	WriteResponse: "write-response",
+	System:        "system",
+}
+
+func (t MessageType) String() string {
+	if s, ok := messageTypes[t]; ok {
+		return s
+	}
+	return fmt.Sprintf("type-%d", byte(t))
+}
+
+// HasValue returns true if messages of this type carry a register value
+func (t MessageType) HasValue() bool {
+	return t == Write || t == Response
+}
+
+const (
+	// Deprecated: use Read
+	Request byte = byte(Read)
+	// Deprecated: use Response
+	Data byte = byte(Response)
+)
+
+const (
 	// byte 1
 	Broadcast byte = 0x79
 )
@@ -45,113 +85,34 @@ func ReceiverId(b []byte) uint16 {
 	return uint16(b[0]&0xF0)<<3 + uint16(b[1]&0x1F)
 }
 
+// Payload returns register and payload of the telegram data. The payload is
+// shorter than two bytes if data is too short, see ParseFrame for validation.
 func Payload(data []byte) (reg uint16, payload []byte) {
-	if data[2] == 0xFA {
+	if len(data) < 3 {
+		return 0, nil
+	}
+
+	idx := 3
+	if data[2] == 0xFA && len(data) >= 5 {
 		reg = binary.BigEndian.Uint16(data[3:5])
-		payload = data[5:7]
+		idx = 5
 	} else {
 		reg = uint16(data[2])
-		payload = data[3:5]
 	}
-	return reg, payload
-}
-
-func Reading(register uint16) *ElsterReading {
-	for _, r := range ElsterReadings {
-		if r.Index == register {
-			return r
-		}
-	}
-	return nil
-}
 
-func DecodeValue(b []byte, t ElsterType) interface{} {
-	if bytes.Equal(b, []byte{0x80, 0x00}) {
-		return nil
+	end := idx + 2
+	if end > len(data) {
+		end = len(data)
 	}
-
-	switch t {
-	case et_little_endian:
-		return float64(binary.LittleEndian.Uint16(b))
-	case et_dec_val:
-		return float64(binary.BigEndian.Uint16(b)) / 10
-	case et_cent_val:
-		return float64(binary.BigEndian.Uint16(b)) / 100
-	case et_mil_val:
-		return float64(binary.BigEndian.Uint16(b)) / 1000
-
-	case et_byte:
-		return b[0]
-
-	case et_zeit:
-		val := binary.BigEndian.Uint16(b)
-		return fmt.Sprintf("%02d:%02d", byte(val&0xff), byte(val>>8))
-	case et_datum:
-		val := binary.BigEndian.Uint16(b)
-		return fmt.Sprintf("%02d.%02d", byte(val>>8), byte(val&0xff))
-	case et_time_domain:
-		val := binary.BigEndian.Uint16(b)
-		if val&0x8080 == 0 {
-			return fmt.Sprintf("%02d:%02d-%02d:%02d",
-				byte((val>>8)/4), byte(15*((val>>8)%4)),
-				byte((val&0xff)/4), byte(15*(val%4)))
-		}
-		return nil
-
-	case et_little_bool:
-		if bytes.Equal(b, []byte{0x01, 0x00}) {
-			return true
-		} else if bytes.Equal(b, []byte{0x00, 0x00}) {
-			return false
-		}
-		log.Fatalf("Invalid little bool % x", b)
-		return nil
-
-	case et_bool:
-		if bytes.Equal(b, []byte{0x00, 0x01}) {
-			return true
-		} else if bytes.Equal(b, []byte{0x00, 0x00}) {
-			return false
-		}
-		log.Fatalf("Invalid bool % x", b)
-		return nil
+	if idx > end {
+		idx = end
 	}
 
-	// default
-	return b
+	return reg, data[idx:end]
 }
 
-func EncodeValue(val interface{}, t ElsterType) []byte {
-	b := []byte{0, 0}
-
-	switch t {
-	case et_little_endian:
-		u := uint16(val.(float64))
-		binary.LittleEndian.PutUint16(b, u)
-	case et_dec_val:
-		u := uint16(val.(float64) * 10)
-		binary.BigEndian.PutUint16(b, u)
-	case et_cent_val:
-		u := uint16(val.(float64) * 100)
-		binary.BigEndian.PutUint16(b, u)
-	case et_mil_val:
-		u := uint16(val.(float64) * 1000)
-		binary.BigEndian.PutUint16(b, u)
-
-	case et_byte:
-		b[0] = val.(byte)
-	case et_little_bool:
-		if val.(bool) {
-			b[0] = 0x01
-		}
-	case et_bool:
-		if val.(bool) {
-			b[1] = 0x01
-		}
-	}
-
-	// default
-	return b
+func Reading(register uint16) *ElsterReading {
+	return DefaultCatalog.ByIndex(register)
 }
 
 func EncodeRegister(b []byte, register uint16) int {
@@ -165,26 +126,40 @@ func EncodeRegister(b []byte, register uint16) int {
 	return 3
 }
 
-func EncodeReceiver(b []byte, receiverId uint16, requestType byte) {
-	b[0] = byte(receiverId>>3)&0xF0 | requestType
+func EncodeReceiver(b []byte, receiverId uint16, requestType MessageType) {
+	b[0] = byte(receiverId>>3)&0xF0 | byte(requestType)
 	b[1] = byte(receiverId) & 0x1F
 }
 
 func RequestFrame(receiverId uint16, reading *ElsterReading) []byte {
 	b := make([]byte, 8)
 
-	EncodeReceiver(b, receiverId, Request)
+	EncodeReceiver(b, receiverId, Read)
 	EncodeRegister(b, reading.Index)
 
 	return b
 }
 
-func DataFrame(receiverId uint16, val interface{}, reading *ElsterReading) []byte {
+// DataFrame constructs a response telegram holding val encoded as the register type
+func DataFrame(receiverId uint16, val interface{}, reading *ElsterReading) ([]byte, error) {
+	payload, err := Encode(val, reading.Type)
+	if err != nil {
+		return nil, err
+	}
+	return payloadFrame(receiverId, Response, reading.Index, payload), nil
+}
+
+// WriteFrame constructs a write telegram setting the register to the raw payload
+func WriteFrame(receiverId uint16, payload []byte, reading *ElsterReading) []byte {
+	return payloadFrame(receiverId, Write, reading.Index, payload)
+}
+
+func payloadFrame(receiverId uint16, requestType MessageType, register uint16, payload []byte) []byte {
 	b := make([]byte, 8)
 
-	EncodeReceiver(b, receiverId, Data)
-	valIdx := EncodeRegister(b, reading.Index)
-	copy(b[valIdx:], EncodeValue(val, reading.Type))
+	EncodeReceiver(b, receiverId, requestType)
+	valIdx := EncodeRegister(b, register)
+	copy(b[valIdx:], payload)
 
 	return b
 }
@@ -2,7 +2,9 @@ package goelster
 
 import (
 	"bytes"
+	"fmt"
 	"testing"
+	"testing/quick"
 )
 
 func TestDecodeReceiverId(t *testing.T) {
@@ -37,8 +39,8 @@ func TestDecodePayload(t *testing.T) {
 }
 
 func TestEncodeFrame(t *testing.T) {
-	r := *Reading(0x0002) // decimal value
-	val := 32.1           // 312 -> 0x0141
+	r := Reading(0x0002) // decimal value
+	val := 32.1          // 312 -> 0x0141
 	rcvr := uint16(0x500)
 	frame := RequestFrame(rcvr, r)
 
@@ -47,23 +49,312 @@ func TestEncodeFrame(t *testing.T) {
 		t.Errorf("Frame incorrect, got: % X, want: % X.", frame, expected)
 	}
 
-	r = *Reading(0x0002) // decimal value
-	val = 32.1           // 312 -> 0x0141
+	r = Reading(0x0002) // decimal value
+	val = 32.1          // 312 -> 0x0141
 	rcvr = uint16(0x500)
-	frame = DataFrame(rcvr, val, r)
+	frame, err := DataFrame(rcvr, val, r)
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	expected = []byte{0xA2, 0x00, 0x02, 0x01, 0x41, 0x00, 0x00, 0x00}
 	if !bytes.Equal(frame, expected) {
 		t.Errorf("Frame incorrect, got: % X, want: % X.", frame, expected)
 	}
 
-	r = *Reading(0x010c) // decimal value
-	val = 32.1           // 321 -> 0x0141
+	r = Reading(0x010c) // decimal value
+	val = 32.1          // 321 -> 0x0141
 	rcvr = uint16(0x68f)
-	frame = DataFrame(rcvr, val, r)
+	frame, err = DataFrame(rcvr, val, r)
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	expected = []byte{0xD2, 0x0F, 0xFA, 0x01, 0x0C, 0x01, 0x41, 0x00}
 	if !bytes.Equal(frame, expected) {
 		t.Errorf("Frame incorrect, got: % X, want: % X.", frame, expected)
 	}
+
+	// out of range values are not sent as zero
+	if frame, err := DataFrame(rcvr, 4000.0, r); err == nil {
+		t.Errorf("Expected error encoding out of range value, got: % X.", frame)
+	}
+
+	r = Reading(0x0a06)
+	payload := []byte{0x01, 0xA4}
+	rcvr = uint16(0x180)
+	frame = WriteFrame(rcvr, payload, r)
+
+	expected = []byte{0x30, 0x00, 0xFA, 0x0A, 0x06, 0x01, 0xA4, 0x00}
+	if !bytes.Equal(frame, expected) {
+		t.Errorf("Frame incorrect, got: % X, want: % X.", frame, expected)
+	}
+}
+
+func TestScaledValues(t *testing.T) {
+	tests := []struct {
+		typ     ElsterType
+		payload []byte
+		val     float64
+	}{
+		{et_dec_val, []byte{0x00, 0x00}, 0},
+		{et_dec_val, []byte{0x01, 0x41}, 32.1},
+		{et_dec_val, []byte{0xFF, 0xFF}, -0.1},
+		{et_dec_val, []byte{0xFF, 0xDD}, -3.5},
+		{et_dec_val, []byte{0x7F, 0xFF}, 3276.7},
+		{et_dec_val, []byte{0x80, 0x01}, -3276.7},
+		{et_cent_val, []byte{0x00, 0x1D}, 0.29},
+		{et_cent_val, []byte{0xFF, 0xE3}, -0.29},
+		{et_cent_val, []byte{0x7F, 0xFF}, 327.67},
+		{et_mil_val, []byte{0x04, 0xD2}, 1.234},
+		{et_mil_val, []byte{0xFB, 0x2E}, -1.234},
+		{et_mil_val, []byte{0x80, 0x01}, -32.767},
+	}
+
+	for _, tc := range tests {
+		val := DecodeValue(tc.payload, tc.typ)
+		if val != tc.val {
+			t.Errorf("Decode % X (type %d) incorrect, got: %v, want: %v.", tc.payload, tc.typ, val, tc.val)
+		}
+
+		b, err := Encode(tc.val, tc.typ)
+		if err != nil || !bytes.Equal(b, tc.payload) {
+			t.Errorf("Encode %v (type %d) incorrect, got: % X (%v), want: % X.", tc.val, tc.typ, b, err, tc.payload)
+		}
+	}
+}
+
+func TestScaledValueSentinel(t *testing.T) {
+	for _, typ := range []ElsterType{et_dec_val, et_cent_val, et_mil_val} {
+		if val := DecodeValue([]byte{0x80, 0x00}, typ); val != nil {
+			t.Errorf("Sentinel (type %d) incorrect, got: %v, want: nil.", typ, val)
+		}
+	}
+}
+
+func TestEnergy(t *testing.T) {
+	tests := []struct {
+		register uint16
+		parts    []uint16
+		values   []uint16
+		kwh      float64
+	}{
+		{0x092b, []uint16{0x092a, 0x092b}, []uint16{500, 12}, 12.5},
+		{0x092d, []uint16{0x092c, 0x092d}, []uint16{345, 12}, 12345},
+		{0x03b6, []uint16{0x03b1, 0x03b6}, []uint16{1, 2}, 2001},
+		{0x0089, []uint16{0x0087, 0x0088, 0x0089}, []uint16{250, 34, 1}, 1034.25},
+	}
+
+	for _, tc := range tests {
+		parts, err := EnergyParts(Reading(tc.register))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(parts) != len(tc.parts) {
+			t.Fatalf("Parts of %04X incorrect, got: %d, want: %d.", tc.register, len(parts), len(tc.parts))
+		}
+		for i, part := range parts {
+			if part.Index != tc.parts[i] {
+				t.Errorf("Part of %04X incorrect, got: %04X, want: %04X.", tc.register, part.Index, tc.parts[i])
+			}
+		}
+
+		if kwh := CombineEnergy(parts, tc.values); kwh != tc.kwh {
+			t.Errorf("Energy of %04X incorrect, got: %v, want: %v.", tc.register, kwh, tc.kwh)
+		}
+	}
+}
+
+func TestCodecErrors(t *testing.T) {
+	if _, err := Decode([]byte{0x00, 0x02}, et_bool); err == nil {
+		t.Errorf("Expected payload error for invalid bool")
+	} else if _, ok := err.(*PayloadError); !ok {
+		t.Errorf("Error type incorrect, got: %T, want: *PayloadError.", err)
+	}
+
+	if _, err := Decode([]byte{0x00}, et_dec_val); err == nil {
+		t.Errorf("Expected payload error for short payload")
+	}
+
+	if _, err := Encode("42", et_dec_val); err == nil {
+		t.Errorf("Expected unsupported type error for string value")
+	} else if _, ok := err.(*UnsupportedTypeError); !ok {
+		t.Errorf("Error type incorrect, got: %T, want: *UnsupportedTypeError.", err)
+	}
+
+	if _, err := Encode(3276.8, et_dec_val); err == nil {
+		t.Errorf("Expected range error for large value")
+	} else if _, ok := err.(*RangeError); !ok {
+		t.Errorf("Error type incorrect, got: %T, want: *RangeError.", err)
+	}
+
+	if _, err := Encode(256, et_byte); err == nil {
+		t.Errorf("Expected range error for large byte")
+	}
+
+	b, err := Encode(42, et_dec_val)
+	if err != nil || !bytes.Equal(b, []byte{0x01, 0xA4}) {
+		t.Errorf("Encode int incorrect, got: % X (%v), want: % X.", b, err, []byte{0x01, 0xA4})
+	}
+
+	b, err = Encode(1, et_bool)
+	if err != nil || !bytes.Equal(b, []byte{0x00, 0x01}) {
+		t.Errorf("Encode bool incorrect, got: % X (%v), want: % X.", b, err, []byte{0x00, 0x01})
+	}
+}
+
+func TestValue(t *testing.T) {
+	tests := []struct {
+		payload []byte
+		typ     ElsterType
+		kind    ValueKind
+		str     string
+		json    string
+	}{
+		{[]byte{0xFF, 0xDD}, et_dec_val, FloatValue, "-3.5", "-3.5"},
+		{[]byte{0x80, 0x00}, et_dec_val, NullValue, "<nil>", "null"},
+		{[]byte{0x00, 0x01}, et_bool, BoolValue, "true", "true"},
+		{[]byte{0x1E, 0x08}, et_zeit, StringValue, "08:30", `"08:30"`},
+		{[]byte{0x01, 0xA4}, none, RawValue, "0x01A4", `"01A4"`},
+	}
+
+	for _, tc := range tests {
+		val, err := Decode(tc.payload, tc.typ)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if val.Kind != tc.kind {
+			t.Errorf("Kind of % X incorrect, got: %d, want: %d.", tc.payload, val.Kind, tc.kind)
+		}
+		if val.String() != tc.str {
+			t.Errorf("String of % X incorrect, got: %s, want: %s.", tc.payload, val, tc.str)
+		}
+		if b, _ := val.MarshalJSON(); string(b) != tc.json {
+			t.Errorf("JSON of % X incorrect, got: %s, want: %s.", tc.payload, b, tc.json)
+		}
+	}
+}
+
+func TestEncodeTime(t *testing.T) {
+	tests := []struct {
+		typ     ElsterType
+		val     interface{}
+		payload []byte
+	}{
+		{et_zeit, "08:30", []byte{0x1E, 0x08}},
+		{et_zeit, "23:59", []byte{0x3B, 0x17}},
+		{et_datum, "24.12", []byte{0x18, 0x0C}},
+		{et_time_domain, "06:00-22:15", []byte{0x18, 0x59}},
+		{et_time_domain, "00:00-24:00", []byte{0x00, 0x60}},
+		{et_time_domain, nil, []byte{0x80, 0x80}},
+	}
+
+	for _, tc := range tests {
+		b, err := Encode(tc.val, tc.typ)
+		if err != nil || !bytes.Equal(b, tc.payload) {
+			t.Errorf("Encode %v as %v incorrect, got: % X (%v), want: % X.", tc.val, tc.typ, b, err, tc.payload)
+		}
+	}
+
+	invalid := []struct {
+		typ ElsterType
+		val string
+	}{
+		{et_zeit, "24:00"},
+		{et_zeit, "8.30"},
+		{et_zeit, "-1:30"},
+		{et_zeit, "+8:30"},
+		{et_zeit, "08: 30"},
+		{et_datum, "32.01"},
+		{et_datum, "01.00"},
+		{et_time_domain, "06:10-22:00"},
+		{et_time_domain, "06:00-24:15"},
+		{et_time_domain, "06:00"},
+		{et_time_domain, "22:00-06:00"},
+		{et_time_domain, "+06:00-22:00"},
+	}
+
+	for _, tc := range invalid {
+		if b, err := Encode(tc.val, tc.typ); err == nil {
+			t.Errorf("Expected error encoding %s as %v, got: % X.", tc.val, tc.typ, b)
+		}
+	}
+
+	if _, err := Encode("22:00-06:00", et_time_domain); err != nil {
+		if _, ok := err.(*RangeError); !ok {
+			t.Errorf("Error type incorrect, got: %T, want: *RangeError.", err)
+		}
+	}
+
+	// dates look like numbers
+	r := Reading(0x000a)
+	if b, err := EncodeReading(ParseValue("24.12", r), r); err != nil || !bytes.Equal(b, []byte{0x18, 0x0C}) {
+		t.Errorf("Encode parsed date incorrect, got: % X (%v), want: 18 0C.", b, err)
+	}
+	if val := ParseValue("24.12", Reading(0x0a06)); val != 24.12 {
+		t.Errorf("Parsed number incorrect, got: %#v, want: 24.12.", val)
+	}
+}
+
+func TestTimeRoundTrip(t *testing.T) {
+	// decoding an encoded value returns the original string
+	roundTrip := func(typ ElsterType, s string) bool {
+		b, err := Encode(s, typ)
+		if err != nil {
+			t.Logf("Encode %s: %v", s, err)
+			return false
+		}
+		return DecodeValue(b, typ) == s
+	}
+
+	zeit := func(hour, minute uint8) bool {
+		return roundTrip(et_zeit, fmt.Sprintf("%02d:%02d", hour%24, minute%60))
+	}
+	datum := func(day, month uint8) bool {
+		return roundTrip(et_datum, fmt.Sprintf("%02d.%02d", day%31+1, month%12+1))
+	}
+	domain := func(start, end uint8) bool {
+		start, end = start%97, end%97
+		if start > end {
+			start, end = end, start
+		}
+		return roundTrip(et_time_domain, fmt.Sprintf("%02d:%02d-%02d:%02d", start/4, 15*(start%4), end/4, 15*(end%4)))
+	}
+
+	// encoding a decoded payload returns the original payload
+	payload := func(typ ElsterType, valid func(hi, lo byte) bool) func(hi, lo byte) bool {
+		return func(hi, lo byte) bool {
+			if !valid(hi, lo) {
+				return true
+			}
+			b := []byte{hi, lo}
+			val, err := Decode(b, typ)
+			if err != nil {
+				return false
+			}
+			enc, err := Encode(val, typ)
+			return err == nil && bytes.Equal(enc, b)
+		}
+	}
+
+	for name, f := range map[string]interface{}{
+		"zeit":   zeit,
+		"datum":  datum,
+		"domain": domain,
+		"zeit payload": payload(et_zeit, func(hi, lo byte) bool {
+			return hi < 60 && lo < 24
+		}),
+		"datum payload": payload(et_datum, func(hi, lo byte) bool {
+			return hi >= 1 && hi <= 31 && lo >= 1 && lo <= 12
+		}),
+		"domain payload": payload(et_time_domain, func(hi, lo byte) bool {
+			return hi <= lo && lo <= 96 || hi == 0x80 && lo == 0x80
+		}),
+	} {
+		if err := quick.Check(f, &quick.Config{MaxCount: 1000}); err != nil {
+			t.Errorf("Round trip %s incorrect: %v.", name, err)
+		}
+	}
 }
@@ -0,0 +1,170 @@
+package goelster
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+/**
+ * Import of the register table from can_progs' KElsterTable.inc
+ * http://juerg5524.ch/list_data.php
+ *
+ * static const ElsterIndex ElsterTable[] =
+ * {
+ *   { "FEHLERMELDUNG"       , 0x0001, 0},
+ *   { "KESSELSOLLTEMP"      , 0x0002, et_dec_val},
+ *   ...
+ * };
+ */
+
+var elsterTableEntry = regexp.MustCompile(`^\s*\{\s*"([^"]+)"\s*,\s*(0x[0-9a-fA-F]+|\d+)\s*,\s*(\w+)\s*\}`)
+
+// ImportElsterTable parses the ElsterTable definitions of can_progs' KElsterTable.inc.
+// If the source contains no ElsterTable declaration, all entries are parsed.
+func ImportElsterTable(r io.Reader) ([]*ElsterReading, error) {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "//"); i >= 0 {
+			line = line[:i]
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	start, end := 0, len(lines)
+	for i, line := range lines {
+		if strings.Contains(line, "ElsterTable[]") {
+			start = i
+			for j := i; j < len(lines); j++ {
+				if strings.HasPrefix(strings.TrimSpace(lines[j]), "};") {
+					end = j
+					break
+				}
+			}
+			break
+		}
+	}
+
+	var readings []*ElsterReading
+	for i := start; i < end; i++ {
+		m := elsterTableEntry.FindStringSubmatch(lines[i])
+		if m == nil {
+			continue
+		}
+
+		index, err := strconv.ParseUint(m[2], 0, 16)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid index '%s'", i+1, m[2])
+		}
+
+		var t ElsterType
+		if m[3] != "0" {
+			if t, err = ParseElsterType(m[3]); err != nil {
+				return nil, fmt.Errorf("line %d: %v", i+1, err)
+			}
+		}
+
+		readings = append(readings, &ElsterReading{
+			Name:  m[1],
+			Index: uint16(index),
+			Type:  t,
+		})
+	}
+
+	if len(readings) == 0 {
+		return nil, fmt.Errorf("no register definitions found")
+	}
+
+	return readings, nil
+}
+
+// ReadingChange is a register whose definition differs between two tables
+type ReadingChange struct {
+	Old *ElsterReading
+	New *ElsterReading
+}
+
+// ReadingsDiff lists the differences between two register tables
+type ReadingsDiff struct {
+	Added   []*ElsterReading
+	Removed []*ElsterReading
+	Changed []ReadingChange
+}
+
+// DiffReadings compares the name, index and type of register definitions.
+// Registers keeping their index but changing name or type are reported as changed.
+func DiffReadings(old []*ElsterReading, updated []*ElsterReading) ReadingsDiff {
+	type key struct {
+		name  string
+		index uint16
+	}
+
+	var diff ReadingsDiff
+
+	oldByKey := make(map[key]*ElsterReading, len(old))
+	for _, r := range old {
+		oldByKey[key{r.Name, r.Index}] = r
+	}
+
+	matched := make(map[*ElsterReading]bool)
+	var unmatched []*ElsterReading
+
+	for _, r := range updated {
+		if o, ok := oldByKey[key{r.Name, r.Index}]; ok && !matched[o] {
+			matched[o] = true
+			if o.Type != r.Type {
+				diff.Changed = append(diff.Changed, ReadingChange{o, r})
+			}
+			continue
+		}
+		unmatched = append(unmatched, r)
+	}
+
+	// remaining old registers by index to detect renames
+	oldByIndex := make(map[uint16][]*ElsterReading)
+	for _, r := range old {
+		if !matched[r] {
+			oldByIndex[r.Index] = append(oldByIndex[r.Index], r)
+		}
+	}
+
+	for _, r := range unmatched {
+		if candidates := oldByIndex[r.Index]; len(candidates) > 0 {
+			oldByIndex[r.Index] = candidates[1:]
+			matched[candidates[0]] = true
+			diff.Changed = append(diff.Changed, ReadingChange{candidates[0], r})
+			continue
+		}
+		diff.Added = append(diff.Added, r)
+	}
+
+	for _, r := range old {
+		if !matched[r] {
+			diff.Removed = append(diff.Removed, r)
+		}
+	}
+
+	return diff
+}
+
+// Report writes a human readable list of all differences
+func (d ReadingsDiff) Report(w io.Writer) {
+	for _, r := range d.Added {
+		fmt.Fprintf(w, "+ %04X %-40s %s\n", r.Index, r.Name, r.Type)
+	}
+	for _, r := range d.Removed {
+		fmt.Fprintf(w, "- %04X %-40s %s\n", r.Index, r.Name, r.Type)
+	}
+	for _, c := range d.Changed {
+		fmt.Fprintf(w, "~ %04X %-40s %s -> %s %s\n", c.New.Index, c.Old.Name, c.Old.Type, c.New.Name, c.New.Type)
+	}
+	fmt.Fprintf(w, "%d added, %d removed, %d changed\n", len(d.Added), len(d.Removed), len(d.Changed))
+}
@@ -0,0 +1,90 @@
+package goelster
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const elsterTableSource = `
+typedef struct
+{
+  const char * Name;
+  unsigned short Index;
+  unsigned char Type;
+} ElsterIndex;
+
+static const ElsterIndex ElsterTable[] =
+{
+  { "FEHLERMELDUNG"                                    , 0x0001, 0},
+  { "KESSELSOLLTEMP"                                   , 0x0002, et_dec_val},
+  // { "AUSKOMMENTIERT"                                , 0x0003, et_dec_val},
+  { "UHRZEIT"                                          , 0x0009, et_zeit}, // Stunde:Minute
+  { "SOFTWARE_NUMMER"                                  , 0x0199, 0},
+};
+
+static const ErrorIndex ErrorList[] =
+{
+  { "Hochdruck", 0x0004, 0},
+};
+`
+
+func TestImportElsterTable(t *testing.T) {
+	readings, err := ImportElsterTable(strings.NewReader(elsterTableSource))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []*ElsterReading{
+		{Name: "FEHLERMELDUNG", Index: 0x0001},
+		{Name: "KESSELSOLLTEMP", Index: 0x0002, Type: et_dec_val},
+		{Name: "UHRZEIT", Index: 0x0009, Type: et_zeit},
+		{Name: "SOFTWARE_NUMMER", Index: 0x0199},
+	}
+
+	if len(readings) != len(expected) {
+		t.Fatalf("Import incorrect, got: %d readings, want: %d.", len(readings), len(expected))
+	}
+	for i, r := range readings {
+		if !reflect.DeepEqual(r, expected[i]) {
+			t.Errorf("Import %d incorrect, got: %+v, want: %+v.", i, r, expected[i])
+		}
+	}
+
+	if _, err := ImportElsterTable(strings.NewReader(`{ "X", 0x0001, et_unknown},`)); err == nil {
+		t.Errorf("Expected error for unknown type")
+	}
+}
+
+func TestDiffReadings(t *testing.T) {
+	old := []*ElsterReading{
+		{Name: "A", Index: 0x0001},
+		{Name: "B", Index: 0x0002},
+		{Name: "C", Index: 0x0003},
+		{Name: "D", Index: 0x0004},
+	}
+	updated := []*ElsterReading{
+		{Name: "A", Index: 0x0001},
+		{Name: "B", Index: 0x0002, Type: et_dec_val},
+		{Name: "C2", Index: 0x0003},
+		{Name: "E", Index: 0x0005},
+	}
+
+	diff := DiffReadings(old, updated)
+	if len(diff.Added) != 1 || diff.Added[0].Name != "E" {
+		t.Errorf("Added incorrect, got: %v.", diff.Added)
+	}
+	if len(diff.Removed) != 1 || diff.Removed[0].Name != "D" {
+		t.Errorf("Removed incorrect, got: %v.", diff.Removed)
+	}
+	if len(diff.Changed) != 2 || diff.Changed[0].New.Name != "B" || diff.Changed[1].New.Name != "C2" {
+		t.Errorf("Changed incorrect, got: %v.", diff.Changed)
+	}
+
+	var buf bytes.Buffer
+	diff.Report(&buf)
+	if !strings.HasSuffix(buf.String(), "1 added, 1 removed, 2 changed\n") {
+		t.Errorf("Report incorrect, got: %s", buf.String())
+	}
+}
@@ -0,0 +1,78 @@
+package goelster
+
+import (
+	"fmt"
+	"strings"
+)
+
+// energyUnits maps register name suffixes of energy counter parts to their factor in kWh
+var energyUnits = []struct {
+	suffix string
+	factor float64
+}{
+	{"_MWH", 1000},
+	{"_KWH", 1},
+	{"_WH", 0.001},
+}
+
+// energyUnit splits a register name into its base name and unit factor in kWh
+func energyUnit(name string) (string, float64, bool) {
+	for _, u := range energyUnits {
+		if strings.HasSuffix(name, u.suffix) {
+			return strings.TrimSuffix(name, u.suffix), u.factor, true
+		}
+	}
+	return "", 0, false
+}
+
+// EnergyParts returns the registers, lowest unit first, that together form the
+// et_double_val or et_triple_val energy counter r. The counter register itself
+// is the last part. The parts are found by name, e.g. WAERMEERTRAG_WW_SUM_MWH
+// consists of WAERMEERTRAG_WW_SUM_KWH and WAERMEERTRAG_WW_SUM_MWH.
+func EnergyParts(r *ElsterReading) ([]*ElsterReading, error) {
+	var count int
+	switch r.Type {
+	case et_double_val:
+		count = 2
+	case et_triple_val:
+		count = 3
+	default:
+		return nil, fmt.Errorf("register %04X is not an energy counter", r.Index)
+	}
+
+	base, factor, ok := energyUnit(r.Name)
+	if !ok {
+		return nil, fmt.Errorf("register %04X has no energy unit", r.Index)
+	}
+
+	parts := []*ElsterReading{r}
+	for len(parts) < count {
+		factor /= 1000
+
+		var name string
+		for _, u := range energyUnits {
+			if u.factor == factor {
+				name = base + u.suffix
+			}
+		}
+
+		part := DefaultCatalog.ByName(name)
+		if name == "" || part == nil {
+			return nil, fmt.Errorf("register %04X has no lower energy part", r.Index)
+		}
+
+		parts = append([]*ElsterReading{part}, parts...)
+	}
+
+	return parts, nil
+}
+
+// CombineEnergy combines the raw part values, lowest unit first, into the total energy in kWh
+func CombineEnergy(parts []*ElsterReading, values []uint16) float64 {
+	var total float64
+	for i, part := range parts {
+		_, factor, _ := energyUnit(part.Name)
+		total += float64(values[i]) * factor
+	}
+	return total
+}
@@ -0,0 +1,160 @@
+package goelster
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+// EnumValue is a named value of an enum register. Aliases are accepted
+// when writing by name.
+type EnumValue struct {
+	Value   uint16   `json:"value" yaml:"value"`
+	Name    string   `json:"name" yaml:"name"`
+	Aliases []string `json:"aliases,omitempty" yaml:"aliases,omitempty"`
+}
+
+// Enum lists the named values of a register
+type Enum []EnumValue
+
+// Name returns the name of value v
+func (e Enum) Name(v uint16) (string, bool) {
+	for _, ev := range e {
+		if ev.Value == v {
+			return ev.Name, true
+		}
+	}
+	return "", false
+}
+
+// Value returns the value named name or one of its aliases, ignoring case
+func (e Enum) Value(name string) (uint16, bool) {
+	for _, ev := range e {
+		if strings.EqualFold(ev.Name, name) {
+			return ev.Value, true
+		}
+		for _, alias := range ev.Aliases {
+			if strings.EqualFold(alias, name) {
+				return ev.Value, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// Names returns the names of all values
+func (e Enum) Names() []string {
+	names := make([]string, len(e))
+	for i, ev := range e {
+		names[i] = ev.Name
+	}
+	return names
+}
+
+// Flag is a named bit of a status register
+type Flag struct {
+	Mask uint16 `json:"mask" yaml:"mask"`
+	Name string `json:"name" yaml:"name"`
+}
+
+// Flags lists the named bits of a status register
+type Flags []Flag
+
+// Set returns the names of the flags set in v. Set bits without name are
+// returned as hex mask.
+func (f Flags) Set(v uint16) []string {
+	names := []string{}
+	for _, flag := range f {
+		if v&flag.Mask == flag.Mask && flag.Mask != 0 {
+			names = append(names, flag.Name)
+			v &^= flag.Mask
+		}
+	}
+	for bit := uint16(1); v != 0 && bit != 0; bit <<= 1 {
+		if v&bit != 0 {
+			names = append(names, fmt.Sprintf("0x%04X", bit))
+			v &^= bit
+		}
+	}
+	return names
+}
+
+// Betriebsarten are the operating modes of et_betriebsart registers like
+// PROGRAMMSCHALTER. Names are those shown by the WPM, can_progs names are
+// accepted as aliases.
+var Betriebsarten = Enum{
+	{Value: 0, Name: "Notbetrieb"},
+	{Value: 1, Name: "Bereitschaft"},
+	{Value: 2, Name: "Automatik", Aliases: []string{"Programmbetrieb"}},
+	{Value: 3, Name: "Tagbetrieb"},
+	{Value: 4, Name: "Absenkbetrieb"},
+	{Value: 5, Name: "Warmwasser", Aliases: []string{"Sommerbetrieb"}},
+	{Value: 6, Name: "Aus"},
+}
+
+// WaermepumpenStatus are the flags of WAERMEPUMPEN_STATUS
+var WaermepumpenStatus = Flags{
+	{0x0001, "Verdichter 1"},
+	{0x0002, "DHC 1"},
+	{0x0004, "DHC 2"},
+	{0x0008, "Pufferladepumpe"},
+	{0x0010, "Warmwasserladepumpe"},
+	{0x0020, "HK 1 Pumpe"},
+	{0x0040, "HK 2 Pumpe"},
+	{0x0080, "Mischer auf"},
+	{0x0100, "EVU-Sperre"},
+	{0x0200, "Quellenpumpe"},
+	{0x0800, "Kuehlkreispumpe"},
+}
+
+// enumInteger returns the integer of payload b that enum values and flag
+// masks refer to. Operating modes are held in the high byte.
+func enumInteger(b []byte, t ElsterType) uint16 {
+	switch t {
+	case et_little_endian:
+		return binary.LittleEndian.Uint16(b)
+	case et_byte:
+		return uint16(b[0])
+	case et_betriebsart:
+		if val := binary.BigEndian.Uint16(b); val&0xff == 0 {
+			return val >> 8
+		}
+	}
+	return binary.BigEndian.Uint16(b)
+}
+
+// decodeEnum replaces the value decoded from b by the name or flags
+// defined for register r
+func decodeEnum(val Value, b []byte, r *ElsterReading) Value {
+	if val.IsNull() || len(b) < 2 {
+		return val
+	}
+
+	v := enumInteger(b, r.Type)
+	if len(r.Enum) > 0 {
+		if name, ok := r.Enum.Name(v); ok {
+			return NewValue(name, r.Type, val.Raw)
+		}
+	} else if len(r.Flags) > 0 {
+		return NewValue(r.Flags.Set(v), r.Type, val.Raw)
+	}
+
+	return val
+}
+
+// encodeEnum returns the payload for the name of an enum value of register r
+func encodeEnum(name string, r *ElsterReading) ([]byte, error) {
+	v, ok := r.Enum.Value(name)
+	if !ok {
+		return nil, fmt.Errorf("unknown value '%s' for register %s, want one of: %s",
+			name, r.Name, strings.Join(r.Enum.Names(), ", "))
+	}
+
+	if r.Type == none {
+		b := make([]byte, 2)
+		binary.BigEndian.PutUint16(b, v)
+		return b, nil
+	}
+
+	return Encode(v, r.Type)
+}
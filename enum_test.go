@@ -0,0 +1,108 @@
+package goelster
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBetriebsart(t *testing.T) {
+	tests := []struct {
+		payload []byte
+		str     string
+	}{
+		{[]byte{0x01, 0x00}, "Bereitschaft"},
+		{[]byte{0x02, 0x00}, "Automatik"},
+		{[]byte{0x05, 0x00}, "Warmwasser"},
+		{[]byte{0x06, 0x00}, "Aus"},
+		{[]byte{0x80, 0x00}, "<nil>"},
+		{[]byte{0x0b, 0x00}, "0x0B00"},
+	}
+
+	for _, tc := range tests {
+		val, err := DecodeReading(tc.payload, Reading(0x0112))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if val.String() != tc.str {
+			t.Errorf("Mode % X incorrect, got: %s, want: %s.", tc.payload, val, tc.str)
+		}
+	}
+
+	for _, name := range []interface{}{"Automatik", "automatik", "Programmbetrieb", 2} {
+		b, err := EncodeReading(name, Reading(0x0112))
+		if err != nil || !bytes.Equal(b, []byte{0x02, 0x00}) {
+			t.Errorf("Encode %v incorrect, got: % X (%v), want: 02 00.", name, b, err)
+		}
+	}
+
+	if _, err := EncodeReading("Urlaub", Reading(0x0112)); err == nil || !strings.Contains(err.Error(), "Bereitschaft") {
+		t.Errorf("Expected error listing modes, got: %v.", err)
+	}
+}
+
+func TestFlags(t *testing.T) {
+	tests := []struct {
+		payload []byte
+		flags   []string
+		str     string
+	}{
+		{[]byte{0x00, 0x00}, []string{}, "-"},
+		{[]byte{0x01, 0x00}, []string{"Verdichter 1"}, "Verdichter 1"},
+		{[]byte{0x11, 0x01}, []string{"Verdichter 1", "Warmwasserladepumpe", "EVU-Sperre"}, "Verdichter 1, Warmwasserladepumpe, EVU-Sperre"},
+		{[]byte{0x00, 0x04}, []string{"0x0400"}, "0x0400"},
+	}
+
+	for _, tc := range tests {
+		val, err := DecodeReading(tc.payload, Reading(0x0062))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if val.Kind != ListValue {
+			t.Errorf("Kind of % X incorrect, got: %d, want: %d.", tc.payload, val.Kind, ListValue)
+		}
+		if !reflect.DeepEqual(val.Interface(), tc.flags) {
+			t.Errorf("Flags of % X incorrect, got: %v, want: %v.", tc.payload, val.Interface(), tc.flags)
+		}
+		if val.String() != tc.str {
+			t.Errorf("String of % X incorrect, got: %s, want: %s.", tc.payload, val, tc.str)
+		}
+	}
+
+	val, _ := DecodeReading([]byte{0x21, 0x00}, Reading(0x0062))
+	if b, _ := val.MarshalJSON(); string(b) != `["Verdichter 1","HK 1 Pumpe"]` {
+		t.Errorf("JSON incorrect, got: %s, want: %s.", b, `["Verdichter 1","HK 1 Pumpe"]`)
+	}
+
+	// bits of PUMPENSTATUS are not documented
+	if val, _ := DecodeReading([]byte{0x21, 0x00}, Reading(0x01d2)); val.Kind != FloatValue {
+		t.Errorf("Kind of PUMPENSTATUS incorrect, got: %d, want: %d.", val.Kind, FloatValue)
+	}
+}
+
+func TestEnumRegisterFile(t *testing.T) {
+	src := `
+- name: LUEFTERSTUFE
+  index: 0x0123
+  type: et_byte
+  enum:
+    - value: 0
+      name: Aus
+    - value: 1
+      name: Stufe 1
+      aliases: [Normal]
+`
+	readings, err := ReadReadings(strings.NewReader(src), FormatYAML)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := readings[0]
+	if b, err := EncodeReading("normal", r); err != nil || !bytes.Equal(b, []byte{0x01, 0x00}) {
+		t.Errorf("Encode incorrect, got: % X (%v), want: 01 00.", b, err)
+	}
+	if val, _ := DecodeReading([]byte{0x01, 0x00}, r); val.String() != "Stufe 1" {
+		t.Errorf("Decode incorrect, got: %v, want: Stufe 1.", val)
+	}
+}
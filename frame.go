@@ -0,0 +1,119 @@
+package goelster
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/brutella/can"
+)
+
+// FrameError is returned when CAN frame data is not a valid Elster telegram.
+type FrameError struct {
+	Data   []byte
+	Reason string
+}
+
+func (e *FrameError) Error() string {
+	return fmt.Sprintf("invalid frame % X: %s", e.Data, e.Reason)
+}
+
+const (
+	// maxId is the largest standard (11 bit) CAN ID
+	maxId = 0x7FF
+	// receiverMask are the bits of a receiver ID that fit into bytes 0 and 1
+	receiverMask = 0x780 | 0x1F
+)
+
+// Frame is an Elster telegram transported by a CAN frame
+type Frame struct {
+	Sender    uint16 // CAN ID of the sending device
+	Receiver  uint16 // CAN ID of the addressed device
+	Type      MessageType
+	Broadcast bool   // telegram is addressed to all devices
+	Register  uint16 // Elster index
+	Extended  bool   // register is encoded using the 0xFA extension
+	Payload   []byte // value, at most two bytes
+}
+
+// ParseFrame decodes the Elster telegram carried by frm
+func ParseFrame(frm can.Frame) (Frame, error) {
+	length := int(frm.Length)
+	if length > len(frm.Data) {
+		return Frame{}, &FrameError{frm.Data[:], "invalid length"}
+	}
+
+	data := frm.Data[:length]
+	if frm.ID > maxId {
+		return Frame{}, &FrameError{data, "not a standard CAN ID"}
+	}
+	if length < 3 {
+		return Frame{}, &FrameError{data, "too short"}
+	}
+
+	f := Frame{
+		Sender:    uint16(frm.ID),
+		Receiver:  ReceiverId(data),
+		Type:      MessageType(data[0] & 0x0F),
+		Broadcast: data[1] == Broadcast,
+		Extended:  data[2] == 0xFA,
+	}
+
+	idx := 3
+	if f.Extended {
+		if length < 5 {
+			return Frame{}, &FrameError{data, "too short for extended register"}
+		}
+		f.Register = binary.BigEndian.Uint16(data[3:5])
+		idx = 5
+	} else {
+		f.Register = uint16(data[2])
+	}
+
+	if length > idx+2 {
+		length = idx + 2
+	}
+	if length > idx {
+		f.Payload = make([]byte, length-idx)
+		copy(f.Payload, data[idx:length])
+	}
+
+	return f, nil
+}
+
+// Marshal encodes the telegram into a CAN frame
+func (f Frame) Marshal() (can.Frame, error) {
+	frm := can.Frame{ID: uint32(f.Sender)}
+
+	if f.Sender > maxId || f.Receiver > maxId {
+		return frm, fmt.Errorf("invalid CAN ID %X.%X", f.Sender, f.Receiver)
+	}
+	if f.Receiver&^receiverMask != 0 {
+		return frm, fmt.Errorf("receiver %X cannot be addressed", f.Receiver)
+	}
+	if f.Type > 0x0F {
+		return frm, fmt.Errorf("invalid message type %d", f.Type)
+	}
+	if len(f.Payload) > 2 {
+		return frm, fmt.Errorf("payload % X too long", f.Payload)
+	}
+
+	b := frm.Data[:]
+	EncodeReceiver(b, f.Receiver, f.Type)
+	if f.Broadcast {
+		b[1] = Broadcast
+	}
+
+	idx := 3
+	if f.Extended || f.Register > 0xFF {
+		b[2] = 0xFA
+		binary.BigEndian.PutUint16(b[3:], f.Register)
+		idx = 5
+	} else {
+		b[2] = byte(f.Register)
+	}
+
+	copy(b[idx:], f.Payload)
+	frm.Length = uint8(idx + len(f.Payload))
+
+	return frm, nil
+}
@@ -0,0 +1,167 @@
+package goelster
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/brutella/can"
+)
+
+func canFrame(id uint32, data ...byte) can.Frame {
+	frm := can.Frame{ID: id, Length: uint8(len(data))}
+	copy(frm.Data[:], data)
+	return frm
+}
+
+func TestParseFrame(t *testing.T) {
+	f, err := ParseFrame(canFrame(0x180, 0xD2, 0x00, 0xFA, 0x09, 0x31, 0x00, 0x27))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := Frame{
+		Sender:   0x180,
+		Receiver: 0x680,
+		Type:     Response,
+		Register: 0x0931,
+		Extended: true,
+		Payload:  []byte{0x00, 0x27},
+	}
+	if !reflect.DeepEqual(f, expected) {
+		t.Errorf("Frame incorrect, got: %+v, want: %+v.", f, expected)
+	}
+
+	f, err = ParseFrame(canFrame(0x680, 0x31, 0x00, 0x0C))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected = Frame{
+		Sender:   0x680,
+		Receiver: 0x180,
+		Type:     Read,
+		Register: 0x000C,
+	}
+	if !reflect.DeepEqual(f, expected) {
+		t.Errorf("Frame incorrect, got: %+v, want: %+v.", f, expected)
+	}
+}
+
+func TestParseFrameLength(t *testing.T) {
+	invalid := []can.Frame{
+		canFrame(0x180),
+		canFrame(0x180, 0xD2, 0x00),
+		canFrame(0x180, 0xD2, 0x00, 0xFA, 0x09),
+		canFrame(0x800, 0xD2, 0x00, 0x0C),
+		{ID: 0x180, Length: 9},
+	}
+
+	for _, frm := range invalid {
+		if _, err := ParseFrame(frm); err == nil {
+			t.Errorf("Expected error for frame % X (length %d)", frm.Data, frm.Length)
+		}
+	}
+}
+
+func TestMarshalFrame(t *testing.T) {
+	f := Frame{
+		Sender:   0x680,
+		Receiver: 0x180,
+		Type:     Read,
+		Register: 0x0931,
+	}
+
+	frm, err := f.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []byte{0x31, 0x00, 0xFA, 0x09, 0x31}
+	if frm.ID != 0x680 || !bytes.Equal(frm.Data[:frm.Length], expected) {
+		t.Errorf("Frame incorrect, got: %X % X, want: %X % X.", frm.ID, frm.Data[:frm.Length], 0x680, expected)
+	}
+
+	f.Payload = []byte{0x01, 0x02, 0x03}
+	if _, err := f.Marshal(); err == nil {
+		t.Errorf("Expected error for long payload")
+	}
+
+	f.Payload = nil
+	f.Receiver = 0x1A8
+	if _, err := f.Marshal(); err == nil {
+		t.Errorf("Expected error for receiver that cannot be addressed")
+	}
+}
+
+func FuzzParseFrame(f *testing.F) {
+	f.Add(uint16(0x180), []byte{0xD2, 0x00, 0xFA, 0x09, 0x31, 0x00, 0x27})
+	f.Add(uint16(0x680), []byte{0x31, 0x00, 0x0C})
+	f.Add(uint16(0x301), []byte{0x06, 0x79, 0xFA, 0x01, 0x0C, 0x01})
+
+	f.Fuzz(func(t *testing.T, id uint16, data []byte) {
+		if len(data) > 8 {
+			data = data[:8]
+		}
+
+		parsed, err := ParseFrame(canFrame(uint32(id), data...))
+		if err != nil {
+			return
+		}
+
+		frm, err := parsed.Marshal()
+		if err != nil {
+			t.Fatalf("Marshal %+v failed: %v", parsed, err)
+		}
+
+		reparsed, err := ParseFrame(frm)
+		if err != nil {
+			t.Fatalf("Parse % X failed: %v", frm.Data, err)
+		}
+
+		if !reflect.DeepEqual(parsed, reparsed) {
+			t.Errorf("Round trip incorrect, got: %+v, want: %+v.", reparsed, parsed)
+		}
+	})
+}
+
+func FuzzMarshalFrame(f *testing.F) {
+	f.Add(uint16(0x680), uint16(0x180), byte(1), false, uint16(0x0931), false, []byte{})
+	f.Add(uint16(0x180), uint16(0x680), byte(2), false, uint16(0x000C), true, []byte{0xFF, 0xDD})
+
+	f.Fuzz(func(t *testing.T, sender uint16, receiver uint16, typ byte, broadcast bool, register uint16, extended bool, payload []byte) {
+		in := Frame{
+			Sender:    sender & maxId,
+			Receiver:  receiver & maxId,
+			Type:      MessageType(typ & 0x0F),
+			Broadcast: broadcast,
+			Register:  register,
+			Extended:  extended || register > 0xFF,
+		}
+		if len(payload) > 2 {
+			payload = payload[:2]
+		}
+		if len(payload) > 0 {
+			in.Payload = payload
+		}
+		in.Receiver &= receiverMask
+		if broadcast {
+			// broadcast replaces the lower receiver bits
+			in.Receiver = in.Receiver&0x780 | uint16(Broadcast&0x1F)
+		}
+
+		frm, err := in.Marshal()
+		if err != nil {
+			t.Fatalf("Marshal %+v failed: %v", in, err)
+		}
+
+		out, err := ParseFrame(frm)
+		if err != nil {
+			t.Fatalf("Parse % X failed: %v", frm.Data, err)
+		}
+
+		if !reflect.DeepEqual(in, out) {
+			t.Errorf("Round trip incorrect, got: %+v, want: %+v.", out, in)
+		}
+	})
+}
@@ -1,6 +1,13 @@
 module github.com/andig/goelster
 
+go 1.18
+
 require (
 	github.com/brutella/can v0.0.0-20180117080637-818f1bc3aba8
 	github.com/urfave/cli v1.20.0
+	go.bug.st/serial v1.6.4
+	golang.org/x/sys v0.19.0
+	gopkg.in/yaml.v2 v2.4.0
 )
+
+require github.com/creack/goselect v0.1.2 // indirect
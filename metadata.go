@@ -0,0 +1,202 @@
+package goelster
+
+import (
+	"strings"
+)
+
+// Category groups registers by meaning
+type Category string
+
+const (
+	CategoryTemperature   Category = "temperature"
+	CategoryEnergy        Category = "energy"
+	CategoryRuntime       Category = "runtime"
+	CategoryTimeProgram   Category = "time program"
+	CategoryError         Category = "error"
+	CategoryConfiguration Category = "configuration"
+)
+
+// readingInfo is metadata of a well-known register
+type readingInfo struct {
+	Unit          string
+	Category      Category
+	ReadOnly      bool
+	Flags         Flags
+	Description   string
+	DescriptionDE string
+}
+
+// readingInfos contains metadata of commonly used registers by name. Write
+// limits differ between devices and are left to register files.
+var readingInfos = map[string]readingInfo{
+	"FEHLERMELDUNG": {
+		Category: CategoryError, ReadOnly: true,
+		Description: "Current error message", DescriptionDE: "Aktuelle Fehlermeldung",
+	},
+	"KESSELSOLLTEMP": {
+		Description: "Boiler set temperature", DescriptionDE: "Kesselsolltemperatur",
+	},
+	"SPEICHERSOLLTEMP": {
+		Description: "DHW tank set temperature", DescriptionDE: "Speichersolltemperatur",
+	},
+	"VORLAUFSOLLTEMP": {
+		Description: "Flow set temperature", DescriptionDE: "Vorlaufsolltemperatur",
+	},
+	"RAUMSOLLTEMP_I": {
+		Description: "Room set temperature comfort 1", DescriptionDE: "Raumsolltemperatur Komfort 1",
+	},
+	"RAUMSOLLTEMP_II": {
+		Description: "Room set temperature comfort 2", DescriptionDE: "Raumsolltemperatur Komfort 2",
+	},
+	"RAUMSOLLTEMP_III": {
+		Description: "Room set temperature comfort 3", DescriptionDE: "Raumsolltemperatur Komfort 3",
+	},
+	"RAUMSOLLTEMP_NACHT": {
+		Description: "Room set temperature setback", DescriptionDE: "Raumsolltemperatur Absenkbetrieb",
+	},
+	"UHRZEIT": {
+		Category:    CategoryConfiguration,
+		Description: "Time of day", DescriptionDE: "Uhrzeit",
+	},
+	"DATUM": {
+		Category:    CategoryConfiguration,
+		Description: "Date", DescriptionDE: "Datum",
+	},
+	"GERAETE_ID": {
+		ReadOnly:    true,
+		Description: "Device identification", DescriptionDE: "Gerätekennung",
+	},
+	"AUSSENTEMP": {
+		Description: "Outdoor temperature", DescriptionDE: "Außentemperatur",
+	},
+	"SPEICHERISTTEMP": {
+		Description: "DHW tank temperature", DescriptionDE: "Speicheristtemperatur",
+	},
+	"VORLAUFISTTEMP": {
+		Description: "Flow temperature", DescriptionDE: "Vorlaufisttemperatur",
+	},
+	"RAUMISTTEMP": {
+		Description: "Room temperature", DescriptionDE: "Raumisttemperatur",
+	},
+	"EINSTELL_SPEICHERSOLLTEMP": {
+		Description: "DHW set temperature", DescriptionDE: "Eingestellte Warmwassersolltemperatur",
+	},
+	"EINSTELL_SPEICHERSOLLTEMP2": {
+		Description: "DHW set temperature 2", DescriptionDE: "Eingestellte Warmwassersolltemperatur 2",
+	},
+	"RUECKLAUFISTTEMP": {
+		Description: "Return temperature", DescriptionDE: "Rücklaufisttemperatur",
+	},
+	"HYSTERESE2": {
+		Description: "Switching hysteresis 2", DescriptionDE: "Schalthysterese 2",
+	},
+	"HEIZKURVE": {
+		Category:    CategoryConfiguration,
+		Description: "Heating curve slope", DescriptionDE: "Steigung der Heizkurve",
+	},
+	"PROGRAMMSCHALTER": {
+		Category:    CategoryConfiguration,
+		Description: "Operating mode", DescriptionDE: "Betriebsart",
+	},
+	"WW_ECO": {
+		Category:    CategoryConfiguration,
+		Description: "DHW eco mode", DescriptionDE: "Warmwasser ECO-Betrieb",
+	},
+	"SOFTWARE_NUMMER": {
+		ReadOnly:    true,
+		Description: "Software number", DescriptionDE: "Softwarenummer",
+	},
+	"SOFTWARE_VERSION": {
+		ReadOnly:    true,
+		Description: "Software version", DescriptionDE: "Softwareversion",
+	},
+	"WPVORLAUFIST": {
+		Unit: "°C", Category: CategoryTemperature, ReadOnly: true,
+		Description: "Heat pump flow temperature", DescriptionDE: "Vorlaufisttemperatur Wärmepumpe",
+	},
+	"HEIZKREIS_STATUS": {
+		ReadOnly:    true,
+		Description: "Heating circuit status flags", DescriptionDE: "Heizkreisstatus",
+	},
+	"WAERMEPUMPEN_STATUS": {
+		ReadOnly:    true,
+		Flags:       WaermepumpenStatus,
+		Description: "Heat pump status flags", DescriptionDE: "Wärmepumpenstatus",
+	},
+	"PUMPENSTATUS": {
+		ReadOnly:    true,
+		Description: "Pump status flags", DescriptionDE: "Pumpenstatus",
+	},
+	"AUSSEN_FROSTTEMP": {
+		Description: "Frost protection outdoor temperature", DescriptionDE: "Frostschutztemperatur außen",
+	},
+}
+
+// energyUnitNames maps energy register name suffixes to units
+var energyUnitNames = map[string]string{
+	"_WH":  "Wh",
+	"_KWH": "kWh",
+	"_MWH": "MWh",
+}
+
+// isActualTemp returns true for registers measuring a temperature
+func isActualTemp(name string) bool {
+	return strings.Contains(name, "ISTTEMP") || strings.Contains(name, "IST_TEMP") || name == "AUSSENTEMP"
+}
+
+// applyMetadata derives unit, category and access of r from its type and name
+// and adds the metadata of well-known registers
+func applyMetadata(r *ElsterReading) {
+	switch r.Type {
+	case et_double_val, et_triple_val:
+		// combined value, see CombineEnergy
+		r.Category, r.Unit, r.ReadOnly = CategoryEnergy, "kWh", true
+	case et_time_domain:
+		r.Category = CategoryTimeProgram
+	case et_err_nr:
+		r.Category, r.ReadOnly = CategoryError, true
+	case et_betriebsart:
+		r.Enum = Betriebsarten
+	}
+
+	if r.Category == "" {
+		for suffix, unit := range energyUnitNames {
+			if strings.HasSuffix(r.Name, suffix) {
+				r.Category, r.ReadOnly = CategoryEnergy, true
+				// parts of untyped energy counters decode as raw bytes
+				if isNumeric(r.Type) {
+					r.Unit = unit
+				}
+			}
+		}
+	}
+
+	if r.Category == "" {
+		switch {
+		case r.Type == et_dec_val && strings.Contains(r.Name, "TEMP"):
+			r.Category, r.Unit = CategoryTemperature, "°C"
+			r.ReadOnly = isActualTemp(r.Name)
+		case strings.Contains(r.Name, "LAUFZEIT"):
+			r.Category = CategoryRuntime
+		case strings.Contains(r.Name, "FEHLER"):
+			r.Category = CategoryError
+		}
+	}
+
+	info, ok := readingInfos[r.Name]
+	if !ok {
+		return
+	}
+
+	if info.Unit != "" {
+		r.Unit = info.Unit
+	}
+	if info.Category != "" {
+		r.Category = info.Category
+	}
+	r.ReadOnly = r.ReadOnly || info.ReadOnly
+	if info.Flags != nil {
+		r.Flags = info.Flags
+	}
+	r.Description, r.DescriptionDE = info.Description, info.DescriptionDE
+}
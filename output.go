@@ -0,0 +1,179 @@
+package goelster
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/brutella/can"
+)
+
+// Output formats of dump, scan and read
+const (
+	OutputText = "text"
+	OutputJSON = "json"
+	OutputCSV  = "csv"
+)
+
+// Output receives the records of dump, scan and read. If nil, results are
+// printed as human readable text.
+var Output RecordWriter
+
+// OutputRecord is a telegram or register value in structured output
+type OutputRecord struct {
+	Time     time.Time `json:"time"`
+	ID       uint32    `json:"-"`
+	Sender   uint16    `json:"-"`
+	Receiver uint16    `json:"-"`
+	Type     string    `json:"type"`
+	Register uint16    `json:"-"`
+	Name     string    `json:"name,omitempty"`
+	Raw      []byte    `json:"-"`
+	Value    *Value    `json:"value,omitempty"`
+	Unit     string    `json:"unit,omitempty"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// recordHeader lists the CSV columns of records
+var recordHeader = []string{
+	"time", "id", "sender", "receiver", "type", "register", "name", "raw", "value", "unit", "error",
+}
+
+// columns returns the fields of the record as strings. CAN IDs, register
+// and raw payload are formatted as hex.
+func (r OutputRecord) columns() []string {
+	var value string
+	if r.Value != nil && !r.Value.IsNull() {
+		value = r.Value.String()
+	}
+
+	return []string{
+		r.Time.Format(time.RFC3339Nano),
+		fmt.Sprintf("%x", r.ID),
+		fmt.Sprintf("%x", r.Sender),
+		fmt.Sprintf("%x", r.Receiver),
+		r.Type,
+		fmt.Sprintf("%04x", r.Register),
+		r.Name,
+		fmt.Sprintf("%X", r.Raw),
+		value,
+		r.Unit,
+		r.Error,
+	}
+}
+
+// MarshalJSON encodes CAN IDs, register and raw payload as hex strings
+func (r OutputRecord) MarshalJSON() ([]byte, error) {
+	type record OutputRecord
+	c := r.columns()
+
+	return json.Marshal(struct {
+		record
+		ID       string `json:"id"`
+		Sender   string `json:"sender"`
+		Receiver string `json:"receiver"`
+		Register string `json:"register"`
+		Raw      string `json:"raw"`
+	}{record(r), c[1], c[2], c[3], c[5], c[7]})
+}
+
+// NewFrameRecord decodes a frame received at time t
+func NewFrameRecord(t time.Time, frm can.Frame) OutputRecord {
+	rec := OutputRecord{
+		Time: t,
+		ID:   frm.ID,
+		Raw:  frm.Data[:frm.Length],
+	}
+
+	f, err := ParseFrame(frm)
+	if err != nil {
+		rec.Error = err.Error()
+		return rec
+	}
+
+	rec.Sender = f.Sender
+	rec.Receiver = f.Receiver
+	rec.Type = f.Type.String()
+	rec.Register = f.Register
+	rec.Raw = f.Payload
+
+	r := Reading(f.Register)
+	if r == nil {
+		return rec
+	}
+	rec.Name = r.Name
+
+	if f.Type.HasValue() {
+		val, err := DecodeReading(f.Payload, r)
+		if err != nil {
+			rec.Error = err.Error()
+			return rec
+		}
+		rec.Value = &val
+		rec.Unit = val.Unit
+	}
+
+	return rec
+}
+
+// NewValueRecord creates the record of a value sent by the device sender to
+// receiver without a single telegram, like combined energy counters
+func NewValueRecord(t time.Time, sender uint16, receiver uint16, r *ElsterReading, val Value) OutputRecord {
+	return OutputRecord{
+		Time:     t,
+		ID:       uint32(sender),
+		Sender:   sender,
+		Receiver: receiver,
+		Type:     Response.String(),
+		Register: r.Index,
+		Name:     r.Name,
+		Raw:      val.Raw,
+		Value:    &val,
+		Unit:     val.Unit,
+	}
+}
+
+// RecordWriter writes records in a structured format
+type RecordWriter interface {
+	Write(rec OutputRecord) error
+}
+
+// NewRecordWriter creates a writer for the json or csv output format. JSON
+// records are newline-delimited.
+func NewRecordWriter(w io.Writer, format string) (RecordWriter, error) {
+	switch format {
+	case OutputJSON:
+		return &jsonRecordWriter{enc: json.NewEncoder(w)}, nil
+	case OutputCSV:
+		return &csvRecordWriter{w: csv.NewWriter(w)}, nil
+	default:
+		return nil, fmt.Errorf("unsupported output format '%s'", format)
+	}
+}
+
+type jsonRecordWriter struct {
+	enc *json.Encoder
+}
+
+func (w *jsonRecordWriter) Write(rec OutputRecord) error {
+	return w.enc.Encode(rec)
+}
+
+type csvRecordWriter struct {
+	w      *csv.Writer
+	header bool
+}
+
+func (w *csvRecordWriter) Write(rec OutputRecord) error {
+	if !w.header {
+		w.w.Write(recordHeader)
+		w.header = true
+	}
+
+	w.w.Write(rec.columns())
+	w.w.Flush()
+
+	return w.w.Error()
+}
@@ -0,0 +1,163 @@
+package goelster
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFrameRecord(t *testing.T) {
+	ts := time.Date(2017, 8, 17, 15, 23, 25, 312412000, time.UTC)
+
+	rec := NewFrameRecord(ts, canFrame(0x180, 0xD2, 0x00, 0xFA, 0x00, 0x0C, 0x00, 0xE1))
+	if rec.Sender != 0x180 || rec.Receiver != 0x680 || rec.Type != "response" || rec.Register != 0x000c ||
+		rec.Name != "AUSSENTEMP" || rec.Value == nil || rec.Value.Float() != 22.5 || rec.Unit != "°C" {
+		t.Errorf("Record incorrect, got: %+v.", rec)
+	}
+
+	b, err := json.Marshal(rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"time":"2017-08-17T15:23:25.312412Z","type":"response","name":"AUSSENTEMP","value":22.5,"unit":"°C",` +
+		`"id":"180","sender":"180","receiver":"680","register":"000c","raw":"00E1"}`
+	if string(b) != want {
+		t.Errorf("JSON incorrect, got: %s, want: %s.", b, want)
+	}
+
+	// requests have no value
+	rec = NewFrameRecord(ts, canFrame(0x680, 0x31, 0x00, 0xFA, 0x00, 0x0C))
+	if rec.Type != "read" || rec.Value != nil || rec.Name != "AUSSENTEMP" {
+		t.Errorf("Record incorrect, got: %+v.", rec)
+	}
+
+	rec = NewFrameRecord(ts, canFrame(0x680, 0x31))
+	if rec.Error == "" || !bytes.Equal(rec.Raw, []byte{0x31}) {
+		t.Errorf("Record incorrect, got: %+v.", rec)
+	}
+}
+
+func TestRecordWriter(t *testing.T) {
+	ts := time.Date(2017, 8, 17, 15, 23, 25, 0, time.UTC)
+	records := []OutputRecord{
+		NewFrameRecord(ts, canFrame(0x180, 0xD2, 0x00, 0xFA, 0x00, 0x0C, 0x00, 0xE1)),
+		NewFrameRecord(ts, canFrame(0x180, 0xD2, 0x00, 0xFA, 0x00, 0x0C, 0x80, 0x00)),
+	}
+
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{OutputJSON, `{"time":"2017-08-17T15:23:25Z","type":"response","name":"AUSSENTEMP","value":22.5,"unit":"°C","id":"180","sender":"180","receiver":"680","register":"000c","raw":"00E1"}
+{"time":"2017-08-17T15:23:25Z","type":"response","name":"AUSSENTEMP","value":null,"unit":"°C","id":"180","sender":"180","receiver":"680","register":"000c","raw":"8000"}
+`},
+		{OutputCSV, `time,id,sender,receiver,type,register,name,raw,value,unit,error
+2017-08-17T15:23:25Z,180,180,680,response,000c,AUSSENTEMP,00E1,22.5,°C,
+2017-08-17T15:23:25Z,180,180,680,response,000c,AUSSENTEMP,8000,,°C,
+`},
+	}
+
+	for _, tc := range tests {
+		var b bytes.Buffer
+		w, err := NewRecordWriter(&b, tc.format)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, rec := range records {
+			if err := w.Write(rec); err != nil {
+				t.Error(err)
+			}
+		}
+
+		if b.String() != tc.want {
+			t.Errorf("%s output incorrect, got: %s, want: %s.", tc.format, b.String(), tc.want)
+		}
+	}
+
+	if _, err := NewRecordWriter(nil, "xml"); err == nil {
+		t.Errorf("Expected error for unsupported format")
+	}
+}
+
+func TestScanOutput(t *testing.T) {
+	bus := NewVirtualBus(VirtualBusOptions{})
+	defer bus.Close()
+
+	sim, err := NewSimulator(0x180, SimulatorProfile{
+		Values: map[string]float64{"AUSSENTEMP": 22.5},
+		Raw:    map[string]string{"WAERMEERTRAG_WW_SUM_KWH": "03e7", "WAERMEERTRAG_WW_SUM_MWH": "000c"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go sim.Run(bus.Connect())
+
+	// CanScan logs the timing of every request
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+	defer func() { Output = nil }()
+
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{OutputJSON, "AUSSENTEMP=22.5°C WAERMEERTRAG_WW_SUM_KWH=03E7 WAERMEERTRAG_WW_SUM_MWH=12999kWh"},
+		{OutputCSV, "AUSSENTEMP=22.5°C WAERMEERTRAG_WW_SUM_KWH=0x03E7 WAERMEERTRAG_WW_SUM_MWH=12999.0kWh"},
+	}
+
+	for _, tc := range tests {
+		format := tc.format
+
+		var b bytes.Buffer
+		Output, _ = NewRecordWriter(&b, format)
+
+		CanScan(bus.Connect(), 0x680, 0x180)
+
+		var records []map[string]string
+		if format == OutputJSON {
+			for _, line := range strings.Split(strings.TrimSpace(b.String()), "\n") {
+				var rec map[string]interface{}
+				if err := json.Unmarshal([]byte(line), &rec); err != nil {
+					t.Fatal(line, err)
+				}
+				fields := make(map[string]string)
+				for k, v := range rec {
+					fields[k] = fmt.Sprint(v)
+				}
+				records = append(records, fields)
+			}
+		} else {
+			rows, err := csv.NewReader(&b).ReadAll()
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, row := range rows[1:] {
+				fields := make(map[string]string)
+				for i, col := range rows[0] {
+					fields[col] = row[i]
+				}
+				records = append(records, fields)
+			}
+		}
+
+		var values []string
+		for _, rec := range records {
+			if rec["sender"] != "180" {
+				t.Errorf("%s sender incorrect, got: %s, want: 180.", format, rec["sender"])
+			}
+			values = append(values, fmt.Sprintf("%s=%s%s", rec["name"], rec["value"], rec["unit"]))
+		}
+
+		if got := strings.Join(values, " "); got != tc.want {
+			t.Errorf("%s scan output incorrect, got: %s, want: %s.", format, got, tc.want)
+		}
+	}
+}
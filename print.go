@@ -3,6 +3,7 @@ package goelster
 import (
 	"fmt"
 	"log"
+	"strings"
 
 	"github.com/brutella/can"
 )
@@ -15,42 +16,37 @@ func LogFrame(frm can.Frame) {
 	length := fmt.Sprintf("[%x]", frm.Length)
 
 	chars := fmt.Sprintf("'%s'", printableString(data[:]))
-	rcvr := ReceiverId(frm.Data[:2])
-	formatted := fmt.Sprintf("%-4x %-3s % -24X %-10s %6x ", frm.ID, length, data, chars, rcvr)
+	formatted := fmt.Sprintf("%-4x %-3s % -24X %-10s ", frm.ID, length, data, chars)
 
-	reg, payload := Payload(data)
-	formatted += fmt.Sprintf("%04X ", reg)
+	f, err := ParseFrame(frm)
+	if err != nil {
+		log.Println(formatted + err.Error())
+		return
+	}
 
-	if data[0]&Data != 0 {
-		if r := Reading(reg); r != nil {
-			val := DecodeValue(payload, r.Type)
-			valStr := ValueString(val)
+	formatted += fmt.Sprintf("%-14s %6x %04X ", f.Type, f.Receiver, f.Register)
 
-			formatted += fmt.Sprintf("%-24s %11s", left(r.Name, 20), valStr)
+	if f.Type.HasValue() {
+		if r := Reading(f.Register); r != nil {
+			if val, err := DecodeReading(f.Payload, r); err == nil {
+				formatted += fmt.Sprintf("%-24s %11s %s", left(r.Name, 20), val, val.Unit)
+			} else {
+				formatted += fmt.Sprintf("%-24s %v", left(r.Name, 20), err)
+			}
 		}
 	}
 
 	log.Println(formatted)
 }
 
-func LogRegisterValue(val interface{}, r *ElsterReading) {
-	valStr := ValueString(val)
-	formatted := fmt.Sprintf("%04X %-24s %11s", r.Index, left(r.Name, 20), valStr)
-	fmt.Println(formatted)
+func LogRegisterValue(val Value, r *ElsterReading) {
+	formatted := fmt.Sprintf("%04X %-24s %11s %s", r.Index, left(r.Name, 20), val, val.Unit)
+	fmt.Println(strings.TrimSpace(formatted))
 }
 
+// ValueString formats a decoded Go value, see Value.String
 func ValueString(val interface{}) string {
-	if _, ok := val.(float64); ok {
-		return fmt.Sprintf("%.1f", val)
-	} else if _, ok := val.(string); ok {
-		return fmt.Sprintf("%s", val)
-	} else if _, ok := val.(bool); ok {
-		return fmt.Sprintf("%t", val)
-	} else if val == nil {
-		return fmt.Sprintf("%v", val)
-	}
-
-	return fmt.Sprintf("0x%04X", val)
+	return NewValue(val, none, nil).String()
 }
 
 func left(s string, chars int) string {
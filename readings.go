@@ -1,5 +1,10 @@
 package goelster
 
+import (
+	"fmt"
+	"strings"
+)
+
 type ElsterType int
 
 const (
@@ -22,12 +27,84 @@ const (
 	et_little_bool
 )
 
+var elsterTypes = map[ElsterType]string{
+	none:             "none",
+	et_dec_val:       "et_dec_val",
+	et_zeit:          "et_zeit",
+	et_datum:         "et_datum",
+	et_dev_id:        "et_dev_id",
+	et_byte:          "et_byte",
+	et_little_endian: "et_little_endian",
+	et_double_val:    "et_double_val",
+	et_triple_val:    "et_triple_val",
+	et_cent_val:      "et_cent_val",
+	et_betriebsart:   "et_betriebsart",
+	et_bool:          "et_bool",
+	et_mil_val:       "et_mil_val",
+	et_dev_nr:        "et_dev_nr",
+	et_err_nr:        "et_err_nr",
+	et_time_domain:   "et_time_domain",
+	et_little_bool:   "et_little_bool",
+}
+
+func (t ElsterType) String() string {
+	if s, ok := elsterTypes[t]; ok {
+		return s
+	}
+	return fmt.Sprintf("et_%d", int(t))
+}
+
+// ParseElsterType returns the type for a name like et_dec_val. Empty names are untyped.
+func ParseElsterType(name string) (ElsterType, error) {
+	if name == "" {
+		return none, nil
+	}
+	for t, s := range elsterTypes {
+		if strings.EqualFold(s, name) {
+			return t, nil
+		}
+	}
+	return none, fmt.Errorf("unknown type '%s'", name)
+}
+
 type ElsterReading struct {
+	Name          string
+	Index         uint16
+	Type          ElsterType
+	Unit          string
+	Scale         float64 // factor applied to numeric values, 0 means unscaled
+	ReadOnly      bool
+	Min           float64 // valid range for writes if Min < Max
+	Max           float64
+	Step          float64  // resolution for writes, 0 means any
+	Default       *float64 // factory setting, set by register files only
+	Enum          Enum     // names of the values, writable by name
+	Flags         Flags    // names of the bits of status registers
+	Category      Category
+	Description   string // English
+	DescriptionDE string // German
+}
+
+// tableEntry is a register definition of the built-in table
+type tableEntry struct {
 	Name  string
 	Index uint16
 	Type  ElsterType
 }
 
+func newReadings(entries []tableEntry) []*ElsterReading {
+	readings := make([]*ElsterReading, len(entries))
+	for i, e := range entries {
+		readings[i] = &ElsterReading{
+			Name:  e.Name,
+			Index: e.Index,
+			Type:  e.Type,
+		}
+		applyMetadata(readings[i])
+	}
+	return readings
+}
+
 var ElsterReadings []*ElsterReading
 
 /**
@@ -38,7 +115,7 @@ var ElsterReadings []*ElsterReading
  */
 
 func init() {
-	ElsterReadings = []*ElsterReading{
+	ElsterReadings = newReadings([]tableEntry{
 		{"FEHLERMELDUNG", 0x0001, 0},
 		{"KESSELSOLLTEMP", 0x0002, et_dec_val},
 		{"SPEICHERSOLLTEMP", 0x0003, et_dec_val},
@@ -1738,7 +1815,8 @@ func init() {
 		{"TEST_OBJEKT_246", 0x0737, 0},
 		{"TEST_OBJEKT_247", 0x0738, 0},
 		{"TEST_OBJEKT_248", 0x0739, 0},
-		{"TEST_OBJEKT_249", 0x073b, 0},
+		// index used by KALIBRIERWERT_1_1
+		//  { "TEST_OBJEKT_249"                                  , 0x073b, 0},
 		{"KALIBRIERWERT_1_1", 0x073b, 0},
 		{"KALIBRIERWERT_1_2", 0x073c, 0},
 		{"KALIBRIERWERT_1_3", 0x073d, 0},
@@ -2643,7 +2721,8 @@ func init() {
 		{"SOLAR_KOLLEKTOR_3_P_ANTEIL", 0x132a, 0},
 		{"SOLAR_KOLLEKTOR_3_I_ANTEIL", 0x1388, 0},
 		{"FEHLERNUMMER", 0x1389, 0},
-		{"REPEAT_MESSAGE_ALL_24H", 0x1389, 0},
+		// index used by FEHLERNUMMER
+		//  { "REPEAT_MESSAGE_ALL_24H"                           , 0x1389, 0},
 		{"LARGE_STATUS_AUSGANG", 0x13b5, 0},
 		{"LARGE_KONFIGURATION_AUSGANG", 0x13b6, 0},
 		{"LARGE_INFO_AN_BEI_AUSGANG", 0x13b7, 0},
@@ -3640,7 +3719,7 @@ func init() {
 		{"AUTOMATIK_WARMWASSER", 0xfdb9, et_little_bool},
 		// ID = 480
 		// EVU Freigabe:         0x0100
-		{"ZWEITER_WE_STATUS", 0xfdba, 0},
+		{"ZWEITER_WE_STATUS_480", 0xfdba, 0},
 		{"WPSTUFEN_WW", 0xfdbb, et_little_endian},
 		{"WW_MIT_2WE", 0xfdbc, et_little_endian},
 		{"SPERREN_2WE", 0xfdbd, et_little_endian},
@@ -3718,5 +3797,7 @@ func init() {
 		{"INFOBLOCK_4", 0xfe05, 0},
 		{"INFOBLOCK_5", 0xfe06, 0},
 		{"INFOBLOCK_6", 0xfe07, 0},
-	}
+	})
+
+	DefaultCatalog = NewCatalog(ElsterReadings)
 }
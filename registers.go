@@ -0,0 +1,548 @@
+package goelster
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Register definition file formats
+const (
+	FormatJSON = "json"
+	FormatYAML = "yaml"
+	FormatCSV  = "csv"
+)
+
+// csvHeader lists the columns of CSV register definitions
+var csvHeader = []string{
+	"name", "index", "type", "unit", "scale", "writable", "min", "max", "step", "default",
+	"enum", "flags", "category", "description", "description_de",
+}
+
+// csvSeparators may not be used in enum and flag names of CSV definitions
+const csvSeparators = ";=|"
+
+// registerIndex is a register index written as hex string like 0x0a06
+type registerIndex uint16
+
+func (i registerIndex) String() string {
+	return fmt.Sprintf("0x%04x", uint16(i))
+}
+
+// parseRegisterIndex parses a hex index with optional 0x prefix like the
+// command line does
+func parseRegisterIndex(s string) (registerIndex, error) {
+	s = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(s)), "0x")
+	i, err := strconv.ParseUint(s, 16, 16)
+	if err != nil {
+		return 0, fmt.Errorf("invalid index '%s'", s)
+	}
+	return registerIndex(i), nil
+}
+
+func (i registerIndex) MarshalJSON() ([]byte, error) {
+	return json.Marshal(i.String())
+}
+
+// UnmarshalJSON accepts hex indexes like "0x0a06" or "0a06". Numbers are read
+// as hex digits like unquoted YAML indexes.
+func (i *registerIndex) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		if !isDigits(string(b)) {
+			return fmt.Errorf("invalid index '%s'", b)
+		}
+		s = string(b)
+	}
+	idx, err := parseRegisterIndex(s)
+	*i = idx
+	return err
+}
+
+// isDigits returns true if s is a non-empty string of decimal digits
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+func (i registerIndex) MarshalYAML() (interface{}, error) {
+	return i.String(), nil
+}
+
+// UnmarshalYAML accepts hex indexes like 0x0a06 or 0a06
+func (i *registerIndex) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var s string
+	if err := unmarshal(&s); err != nil {
+		return err
+	}
+	idx, err := parseRegisterIndex(s)
+	*i = idx
+	return err
+}
+
+// registerDef is the file representation of a register definition
+type registerDef struct {
+	Name          string        `json:"name" yaml:"name"`
+	Index         registerIndex `json:"index" yaml:"index"`
+	Type          string        `json:"type,omitempty" yaml:"type,omitempty"`
+	Unit          string        `json:"unit,omitempty" yaml:"unit,omitempty"`
+	Scale         float64       `json:"scale,omitempty" yaml:"scale,omitempty"`
+	Writable      *bool         `json:"writable,omitempty" yaml:"writable,omitempty"`
+	Min           float64       `json:"min,omitempty" yaml:"min,omitempty"`
+	Max           float64       `json:"max,omitempty" yaml:"max,omitempty"`
+	Step          float64       `json:"step,omitempty" yaml:"step,omitempty"`
+	Default       *float64      `json:"default,omitempty" yaml:"default,omitempty"`
+	Enum          Enum          `json:"enum,omitempty" yaml:"enum,omitempty"`
+	Flags         Flags         `json:"flags,omitempty" yaml:"flags,omitempty"`
+	Category      Category      `json:"category,omitempty" yaml:"category,omitempty"`
+	Description   string        `json:"description,omitempty" yaml:"description,omitempty"`
+	DescriptionDE string        `json:"description_de,omitempty" yaml:"description_de,omitempty"`
+}
+
+func newRegisterDef(r *ElsterReading) registerDef {
+	d := registerDef{
+		Name:          r.Name,
+		Index:         registerIndex(r.Index),
+		Unit:          r.Unit,
+		Scale:         r.Scale,
+		Min:           r.Min,
+		Max:           r.Max,
+		Step:          r.Step,
+		Default:       r.Default,
+		Enum:          r.Enum,
+		Flags:         r.Flags,
+		Category:      r.Category,
+		Description:   r.Description,
+		DescriptionDE: r.DescriptionDE,
+	}
+	if r.Type != none {
+		d.Type = r.Type.String()
+	}
+	if r.ReadOnly {
+		writable := false
+		d.Writable = &writable
+	}
+	return d
+}
+
+func (d registerDef) reading() (*ElsterReading, error) {
+	if d.Name == "" {
+		return nil, fmt.Errorf("register %s has no name", d.Index)
+	}
+
+	t, err := ParseElsterType(d.Type)
+	if err != nil {
+		return nil, fmt.Errorf("register %s: %v", d.Name, err)
+	}
+
+	return &ElsterReading{
+		Name:          d.Name,
+		Index:         uint16(d.Index),
+		Type:          t,
+		Unit:          d.Unit,
+		Scale:         d.Scale,
+		ReadOnly:      d.Writable != nil && !*d.Writable,
+		Min:           d.Min,
+		Max:           d.Max,
+		Step:          d.Step,
+		Default:       d.Default,
+		Enum:          d.Enum,
+		Flags:         d.Flags,
+		Category:      d.Category,
+		Description:   d.Description,
+		DescriptionDE: d.DescriptionDE,
+	}, nil
+}
+
+// FormatForPath returns the register definition format from the file extension
+func FormatForPath(path string) (string, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return FormatJSON, nil
+	case ".yaml", ".yml":
+		return FormatYAML, nil
+	case ".csv":
+		return FormatCSV, nil
+	}
+	return "", fmt.Errorf("unknown register file format '%s'", path)
+}
+
+// LoadReadings reads register definitions from a JSON, YAML or CSV file
+func LoadReadings(path string) ([]*ElsterReading, error) {
+	format, err := FormatForPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	readings, err := ReadReadings(f, format)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	return readings, nil
+}
+
+// ReadReadings reads register definitions in the given format
+func ReadReadings(r io.Reader, format string) ([]*ElsterReading, error) {
+	var defs []registerDef
+	var err error
+
+	switch format {
+	case FormatJSON:
+		dec := json.NewDecoder(r)
+		dec.DisallowUnknownFields()
+		err = dec.Decode(&defs)
+	case FormatYAML:
+		var b []byte
+		if b, err = io.ReadAll(r); err == nil {
+			err = yaml.UnmarshalStrict(b, &defs)
+		}
+	case FormatCSV:
+		defs, err = readCSV(r)
+	default:
+		err = fmt.Errorf("unknown register file format '%s'", format)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	readings := make([]*ElsterReading, 0, len(defs))
+	for _, d := range defs {
+		r, err := d.reading()
+		if err != nil {
+			return nil, err
+		}
+		readings = append(readings, r)
+	}
+
+	return readings, nil
+}
+
+func readCSV(r io.Reader) ([]registerDef, error) {
+	cr := csv.NewReader(r)
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	columns := make(map[string]int)
+	for i, col := range header {
+		columns[strings.ToLower(strings.TrimSpace(col))] = i
+	}
+	for _, col := range []string{"name", "index"} {
+		if _, ok := columns[col]; !ok {
+			return nil, fmt.Errorf("missing column '%s'", col)
+		}
+	}
+
+	var defs []registerDef
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		field := func(col string) string {
+			if i, ok := columns[col]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		number := func(col string) (float64, error) {
+			if s := field(col); s != "" {
+				return strconv.ParseFloat(s, 64)
+			}
+			return 0, nil
+		}
+
+		d := registerDef{
+			Name:          field("name"),
+			Type:          field("type"),
+			Unit:          field("unit"),
+			Category:      Category(field("category")),
+			Description:   field("description"),
+			DescriptionDE: field("description_de"),
+		}
+
+		if d.Index, err = parseRegisterIndex(field("index")); err != nil {
+			return nil, err
+		}
+		if d.Scale, err = number("scale"); err != nil {
+			return nil, err
+		}
+		if d.Min, err = number("min"); err != nil {
+			return nil, err
+		}
+		if d.Max, err = number("max"); err != nil {
+			return nil, err
+		}
+		if d.Step, err = number("step"); err != nil {
+			return nil, err
+		}
+		if s := field("default"); s != "" {
+			def, err := strconv.ParseFloat(s, 64)
+			if err != nil {
+				return nil, err
+			}
+			d.Default = &def
+		}
+		if s := field("writable"); s != "" {
+			writable, err := strconv.ParseBool(s)
+			if err != nil {
+				return nil, err
+			}
+			d.Writable = &writable
+		}
+		if d.Enum, err = parseCSVEnum(field("enum")); err != nil {
+			return nil, err
+		}
+		if d.Flags, err = parseCSVFlags(field("flags")); err != nil {
+			return nil, err
+		}
+
+		defs = append(defs, d)
+	}
+
+	return defs, nil
+}
+
+// parseCSVEnum parses enum values written like 0=Aus;1=Stufe 1|Normal
+func parseCSVEnum(s string) (Enum, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	var e Enum
+	for _, item := range strings.Split(s, ";") {
+		value, names, found := strings.Cut(item, "=")
+		v, err := strconv.ParseUint(strings.TrimSpace(value), 10, 16)
+		if !found || err != nil {
+			return nil, fmt.Errorf("invalid enum value '%s'", item)
+		}
+
+		list := strings.Split(names, "|")
+		ev := EnumValue{Value: uint16(v), Name: strings.TrimSpace(list[0])}
+		for _, alias := range list[1:] {
+			ev.Aliases = append(ev.Aliases, strings.TrimSpace(alias))
+		}
+		e = append(e, ev)
+	}
+
+	return e, nil
+}
+
+// formatCSVEnum writes enum values like 0=Aus;1=Stufe 1|Normal
+func formatCSVEnum(e Enum) (string, error) {
+	items := make([]string, len(e))
+	for i, ev := range e {
+		names := append([]string{ev.Name}, ev.Aliases...)
+		for _, name := range names {
+			if strings.ContainsAny(name, csvSeparators) {
+				return "", fmt.Errorf("enum name '%s' contains one of '%s'", name, csvSeparators)
+			}
+		}
+		items[i] = fmt.Sprintf("%d=%s", ev.Value, strings.Join(names, "|"))
+	}
+	return strings.Join(items, ";"), nil
+}
+
+// parseCSVFlags parses flags written like 0x0001=HK 1 Pumpe;0x0002=HK 2 Pumpe
+func parseCSVFlags(s string) (Flags, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	var f Flags
+	for _, item := range strings.Split(s, ";") {
+		mask, name, found := strings.Cut(item, "=")
+		m, err := strconv.ParseUint(strings.TrimPrefix(strings.ToLower(strings.TrimSpace(mask)), "0x"), 16, 16)
+		if !found || err != nil {
+			return nil, fmt.Errorf("invalid flag '%s'", item)
+		}
+		f = append(f, Flag{Mask: uint16(m), Name: strings.TrimSpace(name)})
+	}
+
+	return f, nil
+}
+
+// formatCSVFlags writes flags like 0x0001=HK 1 Pumpe;0x0002=HK 2 Pumpe
+func formatCSVFlags(f Flags) (string, error) {
+	items := make([]string, len(f))
+	for i, flag := range f {
+		if strings.ContainsAny(flag.Name, csvSeparators) {
+			return "", fmt.Errorf("flag name '%s' contains one of '%s'", flag.Name, csvSeparators)
+		}
+		items[i] = fmt.Sprintf("0x%04x=%s", flag.Mask, flag.Name)
+	}
+	return strings.Join(items, ";"), nil
+}
+
+// WriteReadings writes register definitions in the given format
+func WriteReadings(w io.Writer, readings []*ElsterReading, format string) error {
+	defs := make([]registerDef, len(readings))
+	for i, r := range readings {
+		defs[i] = newRegisterDef(r)
+	}
+
+	switch format {
+	case FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(defs)
+	case FormatYAML:
+		b, err := yaml.Marshal(defs)
+		if err == nil {
+			_, err = w.Write(b)
+		}
+		return err
+	case FormatCSV:
+		return writeCSV(w, defs)
+	}
+
+	return fmt.Errorf("unknown register file format '%s'", format)
+}
+
+func writeCSV(w io.Writer, defs []registerDef) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+
+	number := func(f float64) string {
+		if f == 0 {
+			return ""
+		}
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+
+	for _, d := range defs {
+		writable := ""
+		if d.Writable != nil {
+			writable = strconv.FormatBool(*d.Writable)
+		}
+
+		def := ""
+		if d.Default != nil {
+			def = strconv.FormatFloat(*d.Default, 'f', -1, 64)
+		}
+
+		enum, err := formatCSVEnum(d.Enum)
+		if err != nil {
+			return fmt.Errorf("register %s: %v", d.Name, err)
+		}
+		flags, err := formatCSVFlags(d.Flags)
+		if err != nil {
+			return fmt.Errorf("register %s: %v", d.Name, err)
+		}
+
+		record := []string{
+			d.Name, d.Index.String(), d.Type, d.Unit, number(d.Scale),
+			writable, number(d.Min), number(d.Max), number(d.Step), def,
+			enum, flags, string(d.Category), d.Description, d.DescriptionDE,
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// mergeReading returns a copy of base with all fields set in overlay replaced.
+// Overlays can mark a register read-only but not make it writable.
+func mergeReading(base *ElsterReading, overlay *ElsterReading) *ElsterReading {
+	r := *base
+
+	r.Name = overlay.Name
+	if overlay.Type != none {
+		r.Type = overlay.Type
+	}
+	if overlay.Unit != "" {
+		r.Unit = overlay.Unit
+	}
+	if overlay.Scale != 0 {
+		r.Scale = overlay.Scale
+	}
+	r.ReadOnly = r.ReadOnly || overlay.ReadOnly
+	if overlay.Min < overlay.Max {
+		r.Min, r.Max = overlay.Min, overlay.Max
+	}
+	if overlay.Step != 0 {
+		r.Step = overlay.Step
+	}
+	if overlay.Default != nil {
+		r.Default = overlay.Default
+	}
+	if overlay.Enum != nil {
+		r.Enum = overlay.Enum
+	}
+	if overlay.Flags != nil {
+		r.Flags = overlay.Flags
+	}
+	if overlay.Category != "" {
+		r.Category = overlay.Category
+	}
+	if overlay.Description != "" {
+		r.Description = overlay.Description
+	}
+	if overlay.DescriptionDE != "" {
+		r.DescriptionDE = overlay.DescriptionDE
+	}
+
+	return &r
+}
+
+// MergeReadings returns base with all overlay readings merged over it. Fields
+// set in an overlay reading replace those of the base reading with the same
+// index, overlay readings of new indexes are appended.
+func MergeReadings(base []*ElsterReading, overlay []*ElsterReading) []*ElsterReading {
+	merged := make([]*ElsterReading, len(base))
+	copy(merged, base)
+
+	positions := make(map[uint16]int, len(base))
+	for i := len(base) - 1; i >= 0; i-- {
+		positions[base[i].Index] = i
+	}
+
+	for _, r := range overlay {
+		if i, ok := positions[r.Index]; ok {
+			merged[i] = mergeReading(merged[i], r)
+		} else {
+			positions[r.Index] = len(merged)
+			merged = append(merged, r)
+		}
+	}
+
+	return merged
+}
+
+// UseReadings replaces the register definitions used by all commands
+func UseReadings(readings []*ElsterReading) {
+	ElsterReadings = readings
+	DefaultCatalog = NewCatalog(readings)
+}
@@ -0,0 +1,184 @@
+package goelster
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadWriteReadings(t *testing.T) {
+	readings := []*ElsterReading{
+		{Name: "AUSSENTEMP", Index: 0x000c, Type: et_dec_val, Unit: "°C", ReadOnly: true, Description: "outside, \"measured\""},
+		{Name: "EINSTELL_SPEICHERSOLLTEMP2", Index: 0x0a06, Type: et_dec_val, Unit: "°C", Min: 10, Max: 65},
+		{Name: "LEISTUNG", Index: 0x0123, Scale: 0.5},
+		{Name: "PROGRAMMSCHALTER", Index: 0x0112, Type: et_betriebsart, Enum: Betriebsarten},
+		{Name: "WAERMEPUMPEN_STATUS", Index: 0x0062, Type: et_little_endian, Flags: WaermepumpenStatus},
+	}
+
+	for _, format := range []string{FormatJSON, FormatYAML, FormatCSV} {
+		var buf bytes.Buffer
+		if err := WriteReadings(&buf, readings, format); err != nil {
+			t.Fatalf("Write %s failed: %v", format, err)
+		}
+
+		res, err := ReadReadings(&buf, format)
+		if err != nil {
+			t.Fatalf("Read %s failed: %v", format, err)
+		}
+
+		if !reflect.DeepEqual(res, readings) {
+			t.Errorf("Round trip %s incorrect, got: %+v, want: %+v.", format, res, readings)
+		}
+	}
+
+	invalid := []*ElsterReading{{Name: "LUEFTERSTUFE", Index: 0x0123, Enum: Enum{{Value: 1, Name: "Stufe 1;2"}}}}
+	if err := WriteReadings(&bytes.Buffer{}, invalid, FormatCSV); err == nil {
+		t.Errorf("Expected error for enum name with separator")
+	}
+}
+
+func TestReadReadings(t *testing.T) {
+	tests := []struct {
+		format string
+		data   string
+	}{
+		{FormatJSON, `[{"name": "WPM3_TEMP", "index": "0x1234", "type": "et_dec_val", "writable": false}]`},
+		{FormatYAML, "- name: WPM3_TEMP\n  index: 0x1234\n  type: et_dec_val\n  writable: false\n"},
+		{FormatCSV, "index,name,writable,type\n0x1234,WPM3_TEMP,false,et_dec_val\n"},
+		// indexes are hex with or without prefix
+		{FormatJSON, `[{"name": "WPM3_TEMP", "index": "1234", "type": "et_dec_val", "writable": false}]`},
+		{FormatYAML, "- name: WPM3_TEMP\n  index: 1234\n  type: et_dec_val\n  writable: false\n"},
+		{FormatCSV, "index,name,writable,type\n1234,WPM3_TEMP,false,et_dec_val\n"},
+		// numbers are hex as well
+		{FormatJSON, `[{"name": "WPM3_TEMP", "index": 1234, "type": "et_dec_val", "writable": false}]`},
+	}
+
+	expected := []*ElsterReading{{Name: "WPM3_TEMP", Index: 0x1234, Type: et_dec_val, ReadOnly: true}}
+
+	for _, tc := range tests {
+		res, err := ReadReadings(strings.NewReader(tc.data), tc.format)
+		if err != nil {
+			t.Fatalf("Read %s failed: %v", tc.format, err)
+		}
+		if !reflect.DeepEqual(res, expected) {
+			t.Errorf("Read %s incorrect, got: %+v, want: %+v.", tc.format, res[0], expected[0])
+		}
+	}
+
+	if _, err := ReadReadings(strings.NewReader(`[{"name": "X", "index": 1, "type": "et_unknown"}]`), FormatJSON); err == nil {
+		t.Errorf("Expected error for unknown type")
+	}
+
+	for _, data := range []string{
+		`[{"name": "X", "index": 1.5, "type": "et_dec_val"}]`,
+		`[{"name": "X", "index": "1", "type": "et_dec_val", "unknown": 1}]`,
+	} {
+		if _, err := ReadReadings(strings.NewReader(data), FormatJSON); err == nil {
+			t.Errorf("Expected error for %s", data)
+		}
+	}
+}
+
+func TestMergeReadings(t *testing.T) {
+	base := []*ElsterReading{
+		{Name: "A", Index: 0x0001},
+		{Name: "B", Index: 0x0002, Type: et_dec_val, Category: CategoryTemperature, Description: "B value"},
+	}
+	overlay := []*ElsterReading{
+		{Name: "B2", Index: 0x0002, Unit: "°C"},
+		{Name: "C", Index: 0x0003},
+	}
+
+	merged := MergeReadings(base, overlay)
+
+	expected := []string{"A", "B2", "C"}
+	if len(merged) != len(expected) {
+		t.Fatalf("Merge incorrect, got: %d readings, want: %d.", len(merged), len(expected))
+	}
+	for i, r := range merged {
+		if r.Name != expected[i] {
+			t.Errorf("Merge %d incorrect, got: %s, want: %s.", i, r.Name, expected[i])
+		}
+	}
+	if base[1].Name != "B" || base[1].Unit != "" {
+		t.Errorf("Merge modified base readings")
+	}
+
+	// fields not set in the overlay are kept
+	if r := merged[1]; r.Type != et_dec_val || r.Unit != "°C" || r.Category != CategoryTemperature || r.Description != "B value" {
+		t.Errorf("Merged reading incorrect, got: %+v.", r)
+	}
+}
+
+func TestDecodeEncodeReading(t *testing.T) {
+	r := &ElsterReading{Name: "LEISTUNG", Index: 0x0123, Type: et_dec_val, Unit: "kW", Scale: 2, Min: 0, Max: 10}
+
+	val, err := DecodeReading([]byte{0x00, 0x0A}, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if val.Float() != 2 || val.Unit != "kW" {
+		t.Errorf("Decode incorrect, got: %v %s, want: 2 kW.", val, val.Unit)
+	}
+
+	b, err := EncodeReading(2.0, r)
+	if err != nil || !bytes.Equal(b, []byte{0x00, 0x0A}) {
+		t.Errorf("Encode incorrect, got: % X (%v), want: 00 0A.", b, err)
+	}
+
+	if _, err := EncodeReading(11, r); err == nil {
+		t.Errorf("Expected range error")
+	}
+
+	r.ReadOnly = true
+	if _, err := EncodeReading(2, r); err == nil {
+		t.Errorf("Expected read-only error")
+	}
+}
+
+func TestMetadata(t *testing.T) {
+	tests := []struct {
+		name     string
+		unit     string
+		category Category
+		readOnly bool
+	}{
+		{"AUSSENTEMP", "°C", CategoryTemperature, true},
+		{"EINSTELL_SPEICHERSOLLTEMP2", "°C", CategoryTemperature, false},
+		{"WAERMEERTRAG_WW_SUM_MWH", "kWh", CategoryEnergy, true},
+		{"WAERMEERTRAG_WW_SUM_KWH", "", CategoryEnergy, true}, // untyped part, raw bytes
+		{"LAUFZEIT_WP1", "", CategoryRuntime, false},
+		{"PROGRAMMSCHALTER", "", CategoryConfiguration, false},
+		{"HYSTERESE2", "", "", false}, // untyped
+	}
+
+	for _, tc := range tests {
+		r := DefaultCatalog.ByName(tc.name)
+		if r.Unit != tc.unit || r.Category != tc.category || r.ReadOnly != tc.readOnly {
+			t.Errorf("Metadata of %s incorrect, got: %q %q %v, want: %q %q %v.", tc.name,
+				r.Unit, r.Category, r.ReadOnly, tc.unit, tc.category, tc.readOnly)
+		}
+	}
+
+	r := DefaultCatalog.ByName("EINSTELL_SPEICHERSOLLTEMP2")
+	if r.Description == "" || r.DescriptionDE == "" {
+		t.Errorf("Description of %s missing", r.Name)
+	}
+	if r.Min != 0 || r.Max != 0 || r.Step != 0 {
+		t.Errorf("Limits of %s incorrect, got: %v..%v step %v, want: none.", r.Name, r.Min, r.Max, r.Step)
+	}
+
+	// limits are set by register files
+	overlay := []*ElsterReading{{Name: r.Name, Index: r.Index, Min: 10, Max: 65, Step: 0.1}}
+	r = MergeReadings([]*ElsterReading{r}, overlay)[0]
+	if _, err := EncodeReading(42.05, r); err == nil {
+		t.Errorf("Expected step error")
+	}
+	if _, err := EncodeReading(70, r); err == nil {
+		t.Errorf("Expected range error")
+	}
+	if _, err := EncodeReading(42.5, r); err != nil {
+		t.Errorf("Encode failed: %v", err)
+	}
+}
@@ -0,0 +1,223 @@
+package goelster
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/brutella/can"
+	"gopkg.in/yaml.v2"
+)
+
+// SimulatorProfile describes the registers of a simulated device. Registers
+// are given by name or index.
+type SimulatorProfile struct {
+	Values  map[string]float64 `json:"values,omitempty" yaml:"values,omitempty"` // decoded values like 21.5
+	Raw     map[string]string  `json:"raw,omitempty" yaml:"raw,omitempty"`       // hex payloads like 01a4
+	Signals map[string]Signal  `json:"signals,omitempty" yaml:"signals,omitempty"`
+}
+
+// Signal is a register value following a sine curve, e.g. the outdoor
+// temperature over a day
+type Signal struct {
+	Offset    float64 `json:"offset" yaml:"offset"`
+	Amplitude float64 `json:"amplitude" yaml:"amplitude"`
+	Period    string  `json:"period" yaml:"period"` // duration like 24h
+}
+
+// LoadSimulatorProfile reads a JSON or YAML profile file
+func LoadSimulatorProfile(path string) (SimulatorProfile, error) {
+	var profile SimulatorProfile
+
+	format, err := FormatForPath(path)
+	if err != nil {
+		return profile, err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return profile, err
+	}
+	defer f.Close()
+
+	if profile, err = ReadSimulatorProfile(f, format); err != nil {
+		return profile, fmt.Errorf("%s: %v", path, err)
+	}
+
+	return profile, nil
+}
+
+// ReadSimulatorProfile reads a profile in the given format
+func ReadSimulatorProfile(r io.Reader, format string) (SimulatorProfile, error) {
+	var profile SimulatorProfile
+
+	switch format {
+	case FormatJSON:
+		dec := json.NewDecoder(r)
+		dec.DisallowUnknownFields()
+		err := dec.Decode(&profile)
+		return profile, err
+	case FormatYAML:
+		b, err := io.ReadAll(r)
+		if err == nil {
+			err = yaml.UnmarshalStrict(b, &profile)
+		}
+		return profile, err
+	default:
+		return profile, fmt.Errorf("unsupported profile format '%s'", format)
+	}
+}
+
+// signal is a parsed signal of a register
+type signal struct {
+	reading   *ElsterReading
+	offset    float64
+	amplitude float64
+	period    time.Duration
+}
+
+// Simulator answers read requests addressed to a device id like an Elster
+// controller would. Writes update the stored values.
+type Simulator struct {
+	Id uint16
+
+	mu      sync.Mutex
+	values  map[uint16][]byte
+	signals map[uint16]signal
+	start   time.Time
+	now     func() time.Time
+}
+
+// NewSimulator creates a simulated device answering on id
+func NewSimulator(id uint16, profile SimulatorProfile) (*Simulator, error) {
+	if id > maxId || id&^receiverMask != 0 {
+		return nil, fmt.Errorf("invalid device id %X", id)
+	}
+
+	s := &Simulator{
+		Id:      id,
+		values:  make(map[uint16][]byte),
+		signals: make(map[uint16]signal),
+		now:     time.Now,
+	}
+	s.start = s.now()
+
+	for name, val := range profile.Values {
+		r, err := DefaultCatalog.Lookup(name)
+		if err != nil {
+			return nil, err
+		}
+
+		b, err := encodeReading(val, r)
+		if err != nil {
+			return nil, fmt.Errorf("register %s: %v", r.Name, err)
+		}
+		s.values[r.Index] = b
+	}
+
+	for name, val := range profile.Raw {
+		r, err := DefaultCatalog.Lookup(name)
+		if err != nil {
+			return nil, err
+		}
+
+		b, err := hex.DecodeString(val)
+		if err != nil || len(b) != 2 {
+			return nil, fmt.Errorf("register %s: invalid raw value '%s'", r.Name, val)
+		}
+		s.values[r.Index] = b
+	}
+
+	for name, sig := range profile.Signals {
+		r, err := DefaultCatalog.Lookup(name)
+		if err != nil {
+			return nil, err
+		}
+
+		period, err := time.ParseDuration(sig.Period)
+		if err != nil || period <= 0 {
+			return nil, fmt.Errorf("register %s: invalid period '%s'", r.Name, sig.Period)
+		}
+
+		s.signals[r.Index] = signal{r, sig.Offset, sig.Amplitude, period}
+	}
+
+	return s, nil
+}
+
+// Value returns the current payload of the register. Unsupported registers
+// return the no value payload 0x8000.
+func (s *Simulator) Value(register uint16) []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if sig, ok := s.signals[register]; ok {
+		elapsed := s.now().Sub(s.start)
+		f := sig.offset + sig.amplitude*math.Sin(2*math.Pi*float64(elapsed)/float64(sig.period))
+
+		if b, err := encodeReading(math.Round(f*10)/10, sig.reading); err == nil {
+			return b
+		}
+	}
+
+	if b, ok := s.values[register]; ok {
+		return b
+	}
+
+	return noValue
+}
+
+// Handle processes a telegram and returns the response if any
+func (s *Simulator) Handle(f Frame) (Frame, bool) {
+	if f.Receiver != s.Id || f.Broadcast {
+		return Frame{}, false
+	}
+
+	switch f.Type {
+	case Write:
+		if len(f.Payload) == 2 {
+			s.mu.Lock()
+			s.values[f.Register] = append([]byte{}, f.Payload...)
+			delete(s.signals, f.Register)
+			s.mu.Unlock()
+		}
+	case Read:
+		return Frame{
+			Sender:   s.Id,
+			Receiver: f.Sender,
+			Type:     Response,
+			Register: f.Register,
+			Extended: f.Extended,
+			Payload:  s.Value(f.Register),
+		}, true
+	}
+
+	return Frame{}, false
+}
+
+// Run answers requests received from the transport until it is closed
+func (s *Simulator) Run(t Transport) {
+	unsubscribe := t.Subscribe(func(frm can.Frame) {
+		f, err := ParseFrame(frm)
+		if err != nil {
+			return
+		}
+
+		res, ok := s.Handle(f)
+		if !ok {
+			return
+		}
+
+		if frm, err := res.Marshal(); err == nil {
+			t.Publish(frm)
+		}
+	})
+	defer unsubscribe()
+
+	<-t.Done()
+}
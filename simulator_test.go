@@ -0,0 +1,112 @@
+package goelster
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSimulatorProfile(t *testing.T) {
+	profile, err := ReadSimulatorProfile(strings.NewReader(`{
+		"values": {"EINSTELL_SPEICHERSOLLTEMP": 42.1},
+		"raw": {"0x0199": "0140"},
+		"signals": {"AUSSENTEMP": {"offset": 5, "amplitude": 10, "period": "24h"}}
+	}`), FormatJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sim, err := NewSimulator(0x180, profile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := sim.start
+	sim.now = func() time.Time { return now }
+
+	tests := []struct {
+		elapsed  time.Duration
+		register uint16
+		want     []byte
+	}{
+		{0, 0x0013, []byte{0x01, 0xA5}},
+		{0, 0x0199, []byte{0x01, 0x40}},
+		{0, 0x000c, []byte{0x00, 0x32}},              // 5.0 °C
+		{6 * time.Hour, 0x000c, []byte{0x00, 0x96}},  // 15.0 °C
+		{18 * time.Hour, 0x000c, []byte{0xFF, 0xCE}}, // -5.0 °C
+		{0, 0x0001, noValue},
+	}
+
+	for _, tc := range tests {
+		now = sim.start.Add(tc.elapsed)
+		if got := sim.Value(tc.register); !bytes.Equal(got, tc.want) {
+			t.Errorf("Value %04x after %v incorrect, got: % X, want: % X.", tc.register, tc.elapsed, got, tc.want)
+		}
+	}
+
+	if _, err := ReadSimulatorProfile(strings.NewReader(`{"value": {}}`), FormatJSON); err == nil {
+		t.Errorf("Expected error for unknown field")
+	}
+
+	for _, profile := range []SimulatorProfile{
+		{Values: map[string]float64{"NO_SUCH_REGISTER": 1}},
+		{Raw: map[string]string{"AUSSENTEMP": "01"}},
+		{Signals: map[string]Signal{"AUSSENTEMP": {Period: "daily"}}},
+	} {
+		if _, err := NewSimulator(0x180, profile); err == nil {
+			t.Errorf("Expected error for profile %v", profile)
+		}
+	}
+}
+
+func TestSimulator(t *testing.T) {
+	bus := NewVirtualBus(VirtualBusOptions{Latency: time.Millisecond})
+	defer bus.Close()
+
+	sim, err := NewSimulator(0x180, SimulatorProfile{
+		Values: map[string]float64{"EINSTELL_SPEICHERSOLLTEMP": 42.1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go sim.Run(bus.Connect())
+
+	client := newTestClient(bus.Connect())
+	ctx := context.Background()
+
+	frm, err := client.ReadFrame(ctx, 0x180, 0x0013)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, payload := Payload(frm.Data[:]); !bytes.Equal(payload, []byte{0x01, 0xA5}) {
+		t.Errorf("Payload incorrect, got: % X, want: 01 A5.", payload)
+	}
+
+	// unsupported register
+	frm, err = client.ReadFrame(ctx, 0x180, 0x0001)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, payload := Payload(frm.Data[:]); !bytes.Equal(payload, noValue) {
+		t.Errorf("Payload incorrect, got: % X, want: 80 00.", payload)
+	}
+
+	// extended register
+	frm, err = client.ReadFrame(ctx, 0x180, 0x0a06)
+	if err != nil || frm.Data[2] != 0xFA {
+		t.Errorf("Extended response incorrect, got: %v.", frm)
+	}
+
+	val, err := client.Write(ctx, 0x180, 0x0013, 40.0)
+	if err != nil || !bytes.Equal(val.Raw, []byte{0x01, 0x90}) {
+		t.Errorf("Written payload incorrect, got: % X %v, want: 01 90.", val.Raw, err)
+	}
+
+	// other devices do not answer
+	if _, err := client.ReadFrame(ctx, 0x301, 0x0013); !errors.Is(err, ErrNoResponse) {
+		t.Errorf("Expected no response, got: %v.", err)
+	}
+}
@@ -0,0 +1,230 @@
+package goelster
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+
+	"github.com/brutella/can"
+	"go.bug.st/serial"
+)
+
+// CAN identifier flags of brutella/can frames
+const (
+	canEFFFlag = 0x80000000 // extended frame format
+	canRTRFlag = 0x40000000 // remote transmission request
+	canEFFMask = 0x1FFFFFFF
+	canSFFMask = 0x000007FF
+)
+
+// DefaultBitrate is the bitrate of the Elster CAN bus
+const DefaultBitrate = 20000
+
+// slcanBitrates maps bitrates to the LAWICEL setup commands
+var slcanBitrates = map[int]string{
+	10000:   "S0",
+	20000:   "S1",
+	50000:   "S2",
+	100000:  "S3",
+	125000:  "S4",
+	250000:  "S5",
+	500000:  "S6",
+	800000:  "S7",
+	1000000: "S8",
+}
+
+// FormatSlcan encodes a frame as LAWICEL command without trailing carriage return
+func FormatSlcan(frm can.Frame) (string, error) {
+	if frm.Length > can.MaxFrameDataLength {
+		return "", fmt.Errorf("invalid frame length %d", frm.Length)
+	}
+
+	var s string
+	switch {
+	case frm.ID&canEFFFlag != 0 && frm.ID&canRTRFlag != 0:
+		s = fmt.Sprintf("R%08X", frm.ID&canEFFMask)
+	case frm.ID&canEFFFlag != 0:
+		s = fmt.Sprintf("T%08X", frm.ID&canEFFMask)
+	case frm.ID&^canRTRFlag > canSFFMask:
+		return "", fmt.Errorf("invalid CAN ID %X", frm.ID)
+	case frm.ID&canRTRFlag != 0:
+		s = fmt.Sprintf("r%03X", frm.ID&canSFFMask)
+	default:
+		s = fmt.Sprintf("t%03X", frm.ID)
+	}
+
+	s += strconv.Itoa(int(frm.Length))
+	if frm.ID&canRTRFlag == 0 {
+		s += fmt.Sprintf("%X", frm.Data[:frm.Length])
+	}
+
+	return s, nil
+}
+
+// ParseSlcan decodes a received LAWICEL frame like t68033100FA. A trailing
+// timestamp is ignored.
+func ParseSlcan(s string) (can.Frame, error) {
+	var frm can.Frame
+
+	if len(s) < 1 {
+		return frm, fmt.Errorf("invalid slcan frame '%s'", s)
+	}
+
+	idLen := 3
+	switch s[0] {
+	case 't':
+	case 'r':
+		frm.ID = canRTRFlag
+	case 'T':
+		idLen = 8
+		frm.ID = canEFFFlag
+	case 'R':
+		idLen = 8
+		frm.ID = canEFFFlag | canRTRFlag
+	default:
+		return frm, fmt.Errorf("invalid slcan frame '%s'", s)
+	}
+
+	if len(s) < 2+idLen {
+		return frm, fmt.Errorf("invalid slcan frame '%s'", s)
+	}
+
+	id, err := strconv.ParseUint(s[1:1+idLen], 16, 32)
+	if err != nil || (idLen == 3 && id > canSFFMask) || id > canEFFMask {
+		return frm, fmt.Errorf("invalid slcan frame '%s'", s)
+	}
+	frm.ID |= uint32(id)
+
+	length := s[1+idLen] - '0'
+	if length > can.MaxFrameDataLength {
+		return frm, fmt.Errorf("invalid slcan frame '%s'", s)
+	}
+	frm.Length = length
+
+	if frm.ID&canRTRFlag != 0 {
+		return frm, nil
+	}
+
+	data := s[2+idLen:]
+	if len(data) != 2*int(length) && len(data) != 2*int(length)+4 {
+		return frm, fmt.Errorf("invalid slcan frame '%s'", s)
+	}
+
+	for i := 0; i < int(length); i++ {
+		b, err := strconv.ParseUint(data[2*i:2*i+2], 16, 8)
+		if err != nil {
+			return frm, fmt.Errorf("invalid slcan frame '%s'", s)
+		}
+		frm.Data[i] = byte(b)
+	}
+
+	return frm, nil
+}
+
+// slcanTransport connects to a LAWICEL/slcan adapter like USBtin or CANable
+type slcanTransport struct {
+	handlers
+	port io.ReadWriteCloser
+	mu   sync.Mutex
+	once sync.Once
+	done chan struct{}
+}
+
+// OpenSlcan opens the adapter at the serial device
+func OpenSlcan(device string, bitrate int) (Transport, error) {
+	port, err := serial.Open(device, &serial.Mode{BaudRate: 115200})
+	if err != nil {
+		return nil, err
+	}
+
+	t, err := NewSlcanTransport(port, bitrate)
+	if err != nil {
+		port.Close()
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// NewSlcanTransport sets up the adapter connected to port and starts
+// receiving frames
+func NewSlcanTransport(port io.ReadWriteCloser, bitrate int) (Transport, error) {
+	setup, ok := slcanBitrates[bitrate]
+	if !ok {
+		return nil, fmt.Errorf("unsupported bitrate %d", bitrate)
+	}
+
+	t := &slcanTransport{
+		port: port,
+		done: make(chan struct{}),
+	}
+
+	// clear pending input, close channel, set bitrate and open channel
+	for _, cmd := range []string{"", "", "C", setup, "O"} {
+		if err := t.write(cmd); err != nil {
+			return nil, err
+		}
+	}
+
+	go t.run()
+
+	return t, nil
+}
+
+func (t *slcanTransport) write(cmd string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	_, err := io.WriteString(t.port, cmd+"\r")
+	return err
+}
+
+// run dispatches received frames until the port is closed
+func (t *slcanTransport) run() {
+	defer close(t.done)
+
+	r := bufio.NewReader(t.port)
+	var line []byte
+
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return
+		}
+
+		// commands are answered with CR or BEL on error
+		if b != '\r' && b != '\a' {
+			line = append(line, b)
+			continue
+		}
+
+		if frm, err := ParseSlcan(string(line)); err == nil {
+			t.dispatch(frm)
+		}
+		line = line[:0]
+	}
+}
+
+func (t *slcanTransport) Publish(frm can.Frame) error {
+	s, err := FormatSlcan(frm)
+	if err != nil {
+		return err
+	}
+
+	return t.write(s)
+}
+
+func (t *slcanTransport) Done() <-chan struct{} {
+	return t.done
+}
+
+func (t *slcanTransport) Close() error {
+	var err error
+	t.once.Do(func() {
+		t.write("C")
+		err = t.port.Close()
+	})
+	return err
+}
@@ -0,0 +1,134 @@
+package goelster
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// openPty returns the master side of a new pseudo terminal and the path of
+// its slave side
+func openPty() (*os.File, string, error) {
+	master, err := os.OpenFile("/dev/ptmx", os.O_RDWR|unix.O_NOCTTY, 0)
+	if err != nil {
+		return nil, "", err
+	}
+
+	fd := int(master.Fd())
+	if err := unix.IoctlSetPointerInt(fd, unix.TIOCSPTLCK, 0); err != nil {
+		master.Close()
+		return nil, "", err
+	}
+
+	n, err := unix.IoctlGetInt(fd, unix.TIOCGPTN)
+	if err != nil {
+		master.Close()
+		return nil, "", err
+	}
+
+	return master, fmt.Sprintf("/dev/pts/%d", n), nil
+}
+
+// fakeAdapter answers LAWICEL commands on the master side of a pty
+type fakeAdapter struct {
+	pty      *os.File
+	commands chan string
+}
+
+func newFakeAdapter(t *testing.T) (*fakeAdapter, string) {
+	pty, path, err := openPty()
+	if err != nil {
+		t.Skipf("pty not available: %v", err)
+	}
+
+	a := &fakeAdapter{pty: pty, commands: make(chan string, 100)}
+	go a.run()
+
+	return a, path
+}
+
+func (a *fakeAdapter) run() {
+	r := bufio.NewReader(a.pty)
+	for {
+		cmd, err := r.ReadString('\r')
+		if err != nil {
+			close(a.commands)
+			return
+		}
+
+		cmd = strings.TrimSuffix(cmd, "\r")
+		a.commands <- cmd
+
+		switch {
+		case cmd == "":
+			a.pty.WriteString("\a")
+		case cmd[0] == 't' || cmd[0] == 'T':
+			a.pty.WriteString("z\r")
+		default:
+			a.pty.WriteString("\r")
+		}
+	}
+}
+
+func (a *fakeAdapter) expect(t *testing.T, want string) {
+	t.Helper()
+
+	select {
+	case cmd := <-a.commands:
+		if cmd != want {
+			t.Errorf("Command incorrect, got: %q, want: %q.", cmd, want)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Command %q not received", want)
+	}
+}
+
+func TestSlcanAdapter(t *testing.T) {
+	adapter, path := newFakeAdapter(t)
+	defer adapter.pty.Close()
+
+	bus, err := OpenTransport("slcan://" + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, cmd := range []string{"", "", "C", "S1", "O"} {
+		adapter.expect(t, cmd)
+	}
+
+	// request is sent to the adapter, response received from the bus
+	go func() {
+		if cmd := <-adapter.commands; cmd == "t68053100FA0A06" {
+			adapter.pty.WriteString("t1807D200FA0A0601A41234\r")
+		}
+	}()
+
+	frm, err := newTestClient(bus).ReadFrame(context.Background(), 0x180, 0x0a06)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if frm.ID != 0x180 || frm.Length != 7 || frm.Data[5] != 0x01 || frm.Data[6] != 0xA4 {
+		t.Errorf("Response incorrect, got: %v.", frm)
+	}
+
+	if err := bus.Close(); err != nil {
+		t.Error(err)
+	}
+	adapter.expect(t, "C")
+
+	select {
+	case <-bus.Done():
+	case <-time.After(time.Second):
+		t.Errorf("Transport not done after close")
+	}
+
+	if _, err := OpenTransport("slcan://" + path + "?bitrate=42"); err == nil {
+		t.Errorf("Expected error for unsupported bitrate")
+	}
+}
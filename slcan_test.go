@@ -0,0 +1,48 @@
+package goelster
+
+import (
+	"testing"
+
+	"github.com/brutella/can"
+)
+
+func TestSlcanFrames(t *testing.T) {
+	tests := []struct {
+		s   string
+		frm can.Frame
+	}{
+		{"t68033100FA", canFrame(0x680, 0x31, 0x00, 0xFA)},
+		{"t1805D200FA01A4", canFrame(0x180, 0xD2, 0x00, 0xFA, 0x01, 0xA4)},
+		{"t0010", canFrame(0x001)},
+		{"T1234567820102", can.Frame{ID: canEFFFlag | 0x12345678, Length: 2, Data: [8]byte{0x01, 0x02}}},
+		{"r6802", can.Frame{ID: canRTRFlag | 0x680, Length: 2}},
+		{"R123456780", can.Frame{ID: canEFFFlag | canRTRFlag | 0x12345678}},
+	}
+
+	for _, tc := range tests {
+		s, err := FormatSlcan(tc.frm)
+		if err != nil || s != tc.s {
+			t.Errorf("FormatSlcan incorrect, got: %s %v, want: %s.", s, err, tc.s)
+		}
+
+		frm, err := ParseSlcan(tc.s)
+		if err != nil || frm != tc.frm {
+			t.Errorf("ParseSlcan(%s) incorrect, got: %v %v, want: %v.", tc.s, frm, err, tc.frm)
+		}
+	}
+
+	// timestamp
+	if frm, err := ParseSlcan("t680331000C1A2B"); err != nil || frm != canFrame(0x680, 0x31, 0x00, 0x0C) {
+		t.Errorf("ParseSlcan with timestamp incorrect, got: %v %v.", frm, err)
+	}
+
+	for _, s := range []string{"", "z", "t68", "t6803", "t680931000C", "t8003310000", "tG0000", "t6802310"} {
+		if frm, err := ParseSlcan(s); err == nil {
+			t.Errorf("Expected error parsing '%s', got: %v.", s, frm)
+		}
+	}
+
+	if _, err := FormatSlcan(can.Frame{ID: 0x800}); err == nil {
+		t.Errorf("Expected error formatting invalid ID")
+	}
+}
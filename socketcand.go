@@ -0,0 +1,196 @@
+package goelster
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/brutella/can"
+)
+
+// socketcandTimeout limits connecting and opening the bus
+const socketcandTimeout = 5 * time.Second
+
+// FormatSocketcandSend encodes a frame as socketcand send command
+func FormatSocketcandSend(frm can.Frame) (string, error) {
+	if frm.Length > can.MaxFrameDataLength {
+		return "", fmt.Errorf("invalid frame length %d", frm.Length)
+	}
+
+	var id string
+	switch {
+	case frm.ID&canEFFFlag != 0:
+		id = fmt.Sprintf("%08X", frm.ID&canEFFMask)
+	case frm.ID > canSFFMask:
+		return "", fmt.Errorf("invalid CAN ID %X", frm.ID)
+	default:
+		id = fmt.Sprintf("%03X", frm.ID)
+	}
+
+	s := fmt.Sprintf("< send %s %d ", id, frm.Length)
+	for _, b := range frm.Data[:frm.Length] {
+		s += fmt.Sprintf("%02X ", b)
+	}
+
+	return s + ">", nil
+}
+
+// ParseSocketcandFrame decodes a received frame like < frame 680 1.000000 3100FA >.
+// Data bytes may also be separated by spaces like < frame 680 1.000000 31 00 FA >.
+func ParseSocketcandFrame(s string) (can.Frame, error) {
+	var frm can.Frame
+
+	fields := strings.Fields(strings.TrimSuffix(strings.TrimPrefix(s, "<"), ">"))
+	if len(fields) < 3 || fields[0] != "frame" {
+		return frm, fmt.Errorf("invalid socketcand frame '%s'", s)
+	}
+
+	id, err := strconv.ParseUint(fields[1], 16, 32)
+	if err != nil || id > canEFFMask {
+		return frm, fmt.Errorf("invalid socketcand frame '%s'", s)
+	}
+	frm.ID = uint32(id)
+	if len(fields[1]) == 8 {
+		frm.ID |= canEFFFlag
+	} else if id > canSFFMask {
+		return frm, fmt.Errorf("invalid socketcand frame '%s'", s)
+	}
+
+	data := strings.Join(fields[3:], "")
+	if len(data)%2 != 0 || len(data) > 2*can.MaxFrameDataLength {
+		return frm, fmt.Errorf("invalid socketcand frame '%s'", s)
+	}
+
+	frm.Length = uint8(len(data) / 2)
+	for i := range frm.Data[:frm.Length] {
+		b, err := strconv.ParseUint(data[2*i:2*i+2], 16, 8)
+		if err != nil {
+			return frm, fmt.Errorf("invalid socketcand frame '%s'", s)
+		}
+		frm.Data[i] = byte(b)
+	}
+
+	return frm, nil
+}
+
+// socketcandTransport exchanges frames with a socketcand server in raw mode
+type socketcandTransport struct {
+	handlers
+	conn net.Conn
+	r    *bufio.Reader
+	mu   sync.Mutex
+	done chan struct{}
+}
+
+// OpenSocketcand connects to the socketcand server at address and opens the
+// CAN interface bus
+func OpenSocketcand(address string, bus string) (Transport, error) {
+	conn, err := net.DialTimeout("tcp", address, socketcandTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &socketcandTransport{
+		conn: conn,
+		r:    bufio.NewReader(conn),
+		done: make(chan struct{}),
+	}
+
+	if err := t.handshake(bus); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	go t.run()
+
+	return t, nil
+}
+
+// handshake opens the bus and switches to raw mode
+func (t *socketcandTransport) handshake(bus string) error {
+	t.conn.SetDeadline(time.Now().Add(socketcandTimeout))
+	defer t.conn.SetDeadline(time.Time{})
+
+	for _, cmd := range []string{"", "< open " + bus + " >", "< rawmode >"} {
+		if cmd != "" {
+			if err := t.write(cmd); err != nil {
+				return err
+			}
+		}
+
+		msg, err := t.read()
+		if err != nil {
+			return err
+		}
+
+		want := "< ok >"
+		if cmd == "" {
+			want = "< hi >"
+		}
+
+		if msg != want {
+			return fmt.Errorf("socketcand: unexpected answer '%s'", msg)
+		}
+	}
+
+	return nil
+}
+
+// read returns the next element like < ok >
+func (t *socketcandTransport) read() (string, error) {
+	if _, err := t.r.ReadString('<'); err != nil {
+		return "", err
+	}
+
+	s, err := t.r.ReadString('>')
+	if err != nil {
+		return "", err
+	}
+
+	return "< " + strings.TrimSpace(strings.TrimSuffix(s, ">")) + " >", nil
+}
+
+func (t *socketcandTransport) write(cmd string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	_, err := t.conn.Write([]byte(cmd))
+	return err
+}
+
+// run dispatches received frames until the connection is closed
+func (t *socketcandTransport) run() {
+	defer close(t.done)
+
+	for {
+		msg, err := t.read()
+		if err != nil {
+			return
+		}
+
+		if frm, err := ParseSocketcandFrame(msg); err == nil {
+			t.dispatch(frm)
+		}
+	}
+}
+
+func (t *socketcandTransport) Publish(frm can.Frame) error {
+	s, err := FormatSocketcandSend(frm)
+	if err != nil {
+		return err
+	}
+
+	return t.write(s)
+}
+
+func (t *socketcandTransport) Done() <-chan struct{} {
+	return t.done
+}
+
+func (t *socketcandTransport) Close() error {
+	return t.conn.Close()
+}
@@ -0,0 +1,122 @@
+package goelster
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/brutella/can"
+)
+
+func TestSocketcandFrames(t *testing.T) {
+	tests := []struct {
+		frm  can.Frame
+		send string
+		recv string
+	}{
+		{canFrame(0x680, 0x31, 0x00, 0xFA, 0x0A, 0x06), "< send 680 5 31 00 FA 0A 06 >", "< frame 680 1502983405.312412 3100FA0A06 >"},
+		{canFrame(0x001), "< send 001 0 >", "< frame 1 0.000000 >"},
+		{can.Frame{ID: canEFFFlag | 0x12345678, Length: 1, Data: [8]byte{0x42}}, "< send 12345678 1 42 >", "< frame 12345678 1.0 42 >"},
+	}
+
+	// data bytes separated by spaces
+	if frm, err := ParseSocketcandFrame("< frame 123 23.424242 11 22 33 >"); err != nil || frm != canFrame(0x123, 0x11, 0x22, 0x33) {
+		t.Errorf("ParseSocketcandFrame incorrect, got: %v %v, want: %v.", frm, err, canFrame(0x123, 0x11, 0x22, 0x33))
+	}
+
+	for _, tc := range tests {
+		if s, err := FormatSocketcandSend(tc.frm); err != nil || s != tc.send {
+			t.Errorf("FormatSocketcandSend incorrect, got: %s %v, want: %s.", s, err, tc.send)
+		}
+		if frm, err := ParseSocketcandFrame(tc.recv); err != nil || frm != tc.frm {
+			t.Errorf("ParseSocketcandFrame(%s) incorrect, got: %v %v, want: %v.", tc.recv, frm, err, tc.frm)
+		}
+	}
+
+	for _, s := range []string{"< ok >", "< frame >", "< frame 800 0.0 >", "< frame 680 0.0 310 >", "< frame 680 0.0 3100FA0A0601020304 >", "< frame 680 0.0 XX >"} {
+		if frm, err := ParseSocketcandFrame(s); err == nil {
+			t.Errorf("Expected error parsing '%s', got: %v.", s, frm)
+		}
+	}
+}
+
+// socketcandServer is a stand-in socketcand server providing bus can0 and
+// answering one read request
+func socketcandServer(t *testing.T, l net.Listener, bus string) {
+	conn, err := l.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	expect := func(want string) bool {
+		r.ReadString('<')
+		s, err := r.ReadString('>')
+		if got := "<" + s; err != nil || got != want {
+			t.Errorf("Command incorrect, got: %q, want: %q.", got, want)
+			return false
+		}
+		return true
+	}
+
+	conn.Write([]byte("< hi >"))
+	if !expect("< open " + bus + " >") {
+		return
+	}
+	if bus != "can0" {
+		conn.Write([]byte("< error could not open bus >"))
+		return
+	}
+	conn.Write([]byte("< ok >"))
+	if !expect("< rawmode >") {
+		return
+	}
+	conn.Write([]byte("< ok >"))
+
+	if expect("< send 680 5 31 00 FA 0A 06 >") {
+		conn.Write([]byte("< frame 180 1502983405.312412 D200FA0A0601A4 >"))
+	}
+
+	// wait for the client to disconnect
+	r.ReadString('>')
+}
+
+func TestSocketcandTransport(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	go socketcandServer(t, l, "can0")
+
+	bus, err := OpenTransport("socketcand://" + l.Addr().String() + "/can0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	frm, err := newTestClient(bus).ReadFrame(context.Background(), 0x180, 0x0a06)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if frm != canFrame(0x180, 0xD2, 0x00, 0xFA, 0x0A, 0x06, 0x01, 0xA4) {
+		t.Errorf("Response incorrect, got: %v.", frm)
+	}
+
+	bus.Close()
+	select {
+	case <-bus.Done():
+	case <-time.After(time.Second):
+		t.Errorf("Transport not done after close")
+	}
+
+	// open fails
+	go socketcandServer(t, l, "vcan1")
+	if _, err := OpenTransport("socketcand://" + l.Addr().String() + "/vcan1"); err == nil || !strings.Contains(err.Error(), "could not open") {
+		t.Errorf("Expected error opening bus, got: %v.", err)
+	}
+}
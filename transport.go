@@ -0,0 +1,155 @@
+package goelster
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/brutella/can"
+)
+
+// Transport connects to a CAN bus
+type Transport interface {
+	// Publish sends a frame to the bus
+	Publish(frm can.Frame) error
+	// Subscribe calls fn for every frame received from the bus until the
+	// returned function is called
+	Subscribe(fn func(can.Frame)) (unsubscribe func())
+	// Done is closed when the transport stops receiving frames
+	Done() <-chan struct{}
+	// Close disconnects from the bus
+	Close() error
+}
+
+// handler is a subscribed function
+type handler struct {
+	id int
+	fn func(can.Frame)
+}
+
+// handlers dispatches received frames to subscribed functions
+type handlers struct {
+	mu   sync.Mutex
+	next int
+	list []handler // in order of subscription
+}
+
+// Subscribe adds fn until the returned function is called
+func (h *handlers) Subscribe(fn func(can.Frame)) func() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	id := h.next
+	h.next++
+	h.list = append(h.list, handler{id, fn})
+
+	return func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+
+		for i, hd := range h.list {
+			if hd.id == id {
+				h.list = append(h.list[:i:i], h.list[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+// dispatch calls all subscribed functions in order of subscription
+func (h *handlers) dispatch(frm can.Frame) {
+	h.mu.Lock()
+	list := h.list
+	h.mu.Unlock()
+
+	for _, hd := range list {
+		hd.fn(frm)
+	}
+}
+
+// busTransport adapts a brutella/can bus
+type busTransport struct {
+	handlers
+	bus  *can.Bus
+	done chan struct{}
+}
+
+// NewBusTransport creates a transport for the bus and starts receiving frames
+func NewBusTransport(bus *can.Bus) Transport {
+	t := &busTransport{
+		bus:  bus,
+		done: make(chan struct{}),
+	}
+
+	bus.SubscribeFunc(t.dispatch)
+
+	go func() {
+		bus.ConnectAndPublish()
+		close(t.done)
+	}()
+
+	return t
+}
+
+func (t *busTransport) Publish(frm can.Frame) error {
+	return t.bus.Publish(frm)
+}
+
+func (t *busTransport) Done() <-chan struct{} {
+	return t.done
+}
+
+func (t *busTransport) Close() error {
+	return t.bus.Disconnect()
+}
+
+// OpenTransport connects to the CAN bus of the network interface like can0.
+// Other backends are given as URL:
+//
+//	slcan:///dev/ttyACM0?bitrate=20000    serial slcan adapter
+//	cannelloni://host:20000?local=:20000  cannelloni UDP gateway
+//	socketcand://host:29536/can0          socketcand TCP gateway
+//	replay://capture.log?speed=10         candump log, speed 0 is unthrottled
+func OpenTransport(device string) (Transport, error) {
+	u, err := url.Parse(device)
+	if err != nil || u.Scheme == "" {
+		bus, err := can.NewBusForInterfaceWithName(device)
+		if err != nil {
+			return nil, err
+		}
+
+		return NewBusTransport(bus), nil
+	}
+
+	bitrate := DefaultBitrate
+	if s := u.Query().Get("bitrate"); s != "" {
+		if bitrate, err = strconv.Atoi(s); err != nil {
+			return nil, fmt.Errorf("invalid bitrate '%s'", s)
+		}
+	}
+
+	switch u.Scheme {
+	case "replay":
+		speed := 1.0
+		if s := u.Query().Get("speed"); s != "" {
+			if speed, err = strconv.ParseFloat(s, 64); err != nil {
+				return nil, fmt.Errorf("invalid speed '%s'", s)
+			}
+		}
+		return OpenReplay(u.Host+u.Path, speed)
+	case "slcan":
+		return OpenSlcan(u.Path, bitrate)
+	case "cannelloni":
+		return OpenCannelloni(u.Host, u.Query().Get("local"))
+	case "socketcand":
+		bus := strings.Trim(u.Path, "/")
+		if bus == "" {
+			return nil, fmt.Errorf("missing socketcand bus in '%s'", device)
+		}
+		return OpenSocketcand(u.Host, bus)
+	default:
+		return nil, fmt.Errorf("unsupported transport '%s'", u.Scheme)
+	}
+}
@@ -0,0 +1,35 @@
+package goelster
+
+import (
+	"testing"
+
+	"github.com/brutella/can"
+)
+
+func TestHandlers(t *testing.T) {
+	var h handlers
+	var calls []int
+
+	subscribe := func(n int) func() {
+		return h.Subscribe(func(can.Frame) { calls = append(calls, n) })
+	}
+
+	unsubscribe := []func(){subscribe(0), subscribe(1), subscribe(2)}
+	unsubscribe[1]()
+	unsubscribe[1]() // repeated calls are ignored
+	subscribe(3)
+
+	h.dispatch(can.Frame{})
+
+	if len(calls) != 3 || calls[0] != 0 || calls[1] != 2 || calls[2] != 3 {
+		t.Errorf("Calls incorrect, got: %v, want: [0 2 3].", calls)
+	}
+
+	// unsubscribed handlers are removed
+	for i := 0; i < 1000; i++ {
+		subscribe(i)()
+	}
+	if len(h.list) != 3 {
+		t.Errorf("Handlers incorrect, got: %d, want: 3.", len(h.list))
+	}
+}
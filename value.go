@@ -0,0 +1,123 @@
+package goelster
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// ValueKind describes the Go type held by a Value
+type ValueKind int
+
+const (
+	NullValue ValueKind = iota
+	FloatValue
+	ByteValue
+	StringValue
+	BoolValue
+	RawValue
+	ListValue
+)
+
+// Value is a decoded register value. It carries the elster type and raw
+// payload it was decoded from together with the decoded Go value.
+type Value struct {
+	Kind ValueKind
+	Type ElsterType
+	Raw  []byte
+	Unit string
+	val  interface{}
+}
+
+// NewValue creates a Value from a decoded Go value. Supported Go types are
+// float64, byte, string, bool, []byte and []string, nil creates a null value.
+func NewValue(val interface{}, t ElsterType, raw []byte) Value {
+	v := Value{Type: t, Raw: raw, val: val}
+
+	switch val.(type) {
+	case float64:
+		v.Kind = FloatValue
+	case byte:
+		v.Kind = ByteValue
+	case string:
+		v.Kind = StringValue
+	case bool:
+		v.Kind = BoolValue
+	case []byte:
+		v.Kind = RawValue
+	case []string:
+		v.Kind = ListValue
+	default:
+		v.val = nil
+	}
+
+	return v
+}
+
+// Interface returns the decoded Go value
+func (v Value) Interface() interface{} {
+	return v.val
+}
+
+// IsNull returns true if the register holds no value
+func (v Value) IsNull() bool {
+	return v.Kind == NullValue
+}
+
+// Float returns numeric values as float64. Booleans are returned as 0 or 1,
+// all other kinds as 0.
+func (v Value) Float() float64 {
+	switch v.Kind {
+	case FloatValue:
+		return v.val.(float64)
+	case ByteValue:
+		return float64(v.val.(byte))
+	case BoolValue:
+		if v.val.(bool) {
+			return 1
+		}
+	}
+	return 0
+}
+
+// Bool returns boolean values. Numeric values are true if not zero.
+func (v Value) Bool() bool {
+	if v.Kind == BoolValue {
+		return v.val.(bool)
+	}
+	return v.Float() != 0
+}
+
+// String formats the value without unit
+func (v Value) String() string {
+	switch v.Kind {
+	case FloatValue:
+		return fmt.Sprintf("%.1f", v.val)
+	case StringValue:
+		return v.val.(string)
+	case BoolValue:
+		return fmt.Sprintf("%t", v.val)
+	case NullValue:
+		return fmt.Sprintf("%v", nil)
+	case ListValue:
+		if list := v.val.([]string); len(list) > 0 {
+			return strings.Join(list, ", ")
+		}
+		return "-"
+	}
+
+	return fmt.Sprintf("0x%04X", v.val)
+}
+
+// MarshalJSON encodes the value as JSON null, number, string, boolean or
+// array of strings. Raw values are encoded as hex string.
+func (v Value) MarshalJSON() ([]byte, error) {
+	switch v.Kind {
+	case NullValue:
+		return []byte("null"), nil
+	case RawValue:
+		return json.Marshal(fmt.Sprintf("%X", v.val))
+	}
+
+	return json.Marshal(v.val)
+}
@@ -0,0 +1,169 @@
+package goelster
+
+import (
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/brutella/can"
+)
+
+// VirtualBusOptions configures the delivery of frames on a virtual bus
+type VirtualBusOptions struct {
+	Latency time.Duration // delay of every frame
+	Jitter  time.Duration // random additional delay, frames may be reordered
+	Loss    float64       // probability of dropping a frame
+	Seed    int64         // random seed for jitter and loss
+}
+
+// RecordedFrame is a frame sent on the virtual bus
+type RecordedFrame struct {
+	Time    time.Time
+	Frame   can.Frame
+	Dropped bool
+}
+
+// VirtualBus is an in-process CAN bus connecting any number of participants
+type VirtualBus struct {
+	opts VirtualBusOptions
+
+	mu      sync.Mutex
+	rnd     *rand.Rand
+	nodes   []*virtualNode
+	traffic []RecordedFrame
+}
+
+// NewVirtualBus creates a virtual bus
+func NewVirtualBus(opts VirtualBusOptions) *VirtualBus {
+	return &VirtualBus{
+		opts: opts,
+		rnd:  rand.New(rand.NewSource(opts.Seed)),
+	}
+}
+
+// Connect adds a participant to the bus. Frames published by a participant
+// are received by all other participants.
+func (b *VirtualBus) Connect() Transport {
+	n := &virtualNode{
+		bus:   b,
+		queue: make(chan can.Frame, 1024),
+		done:  make(chan struct{}),
+	}
+
+	b.mu.Lock()
+	b.nodes = append(b.nodes, n)
+	b.mu.Unlock()
+
+	go n.run()
+
+	return n
+}
+
+// Traffic returns all frames published so far
+func (b *VirtualBus) Traffic() []RecordedFrame {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	traffic := make([]RecordedFrame, len(b.traffic))
+	copy(traffic, b.traffic)
+	return traffic
+}
+
+// Close disconnects all participants
+func (b *VirtualBus) Close() error {
+	b.mu.Lock()
+	nodes := b.nodes
+	b.mu.Unlock()
+
+	for _, n := range nodes {
+		n.Close()
+	}
+	return nil
+}
+
+func (b *VirtualBus) publish(from *virtualNode, frm can.Frame) {
+	type delivery struct {
+		node  *virtualNode
+		delay time.Duration
+	}
+
+	b.mu.Lock()
+	dropped := b.opts.Loss > 0 && b.rnd.Float64() < b.opts.Loss
+	b.traffic = append(b.traffic, RecordedFrame{time.Now(), frm, dropped})
+
+	var deliveries []delivery
+	if !dropped {
+		for _, n := range b.nodes {
+			if n == from {
+				continue
+			}
+
+			delay := b.opts.Latency
+			if b.opts.Jitter > 0 {
+				delay += time.Duration(b.rnd.Int63n(int64(b.opts.Jitter)))
+			}
+			deliveries = append(deliveries, delivery{n, delay})
+		}
+	}
+	b.mu.Unlock()
+
+	for _, d := range deliveries {
+		if d.delay == 0 {
+			d.node.enqueue(frm)
+			continue
+		}
+
+		n := d.node
+		time.AfterFunc(d.delay, func() { n.enqueue(frm) })
+	}
+}
+
+// virtualNode is a participant of the virtual bus
+type virtualNode struct {
+	handlers
+	bus   *VirtualBus
+	queue chan can.Frame
+	once  sync.Once
+	done  chan struct{}
+}
+
+func (n *virtualNode) run() {
+	for {
+		select {
+		case frm := <-n.queue:
+			n.dispatch(frm)
+		case <-n.done:
+			return
+		}
+	}
+}
+
+func (n *virtualNode) enqueue(frm can.Frame) {
+	select {
+	case n.queue <- frm:
+	case <-n.done:
+	}
+}
+
+func (n *virtualNode) Publish(frm can.Frame) error {
+	select {
+	case <-n.done:
+		return errors.New("virtual bus participant closed")
+	default:
+	}
+
+	n.bus.publish(n, frm)
+	return nil
+}
+
+func (n *virtualNode) Done() <-chan struct{} {
+	return n.done
+}
+
+func (n *virtualNode) Close() error {
+	n.once.Do(func() {
+		close(n.done)
+	})
+	return nil
+}
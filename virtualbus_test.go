@@ -0,0 +1,121 @@
+package goelster
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/brutella/can"
+)
+
+// responder answers read and write requests addressed to id from values.
+// Registers without value are answered with the no value payload.
+type responder struct {
+	mu     sync.Mutex
+	t      Transport
+	id     uint16
+	values map[uint16][]byte
+	reads  map[uint16]int
+}
+
+func newResponder(t Transport, id uint16, values map[uint16][]byte) *responder {
+	r := &responder{t: t, id: id, values: values, reads: make(map[uint16]int)}
+	t.Subscribe(r.handle)
+	return r
+}
+
+func (r *responder) handle(frm can.Frame) {
+	f, err := ParseFrame(frm)
+	if err != nil || f.Receiver != r.id {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	switch f.Type {
+	case Write:
+		r.values[f.Register] = f.Payload
+	case Read:
+		r.reads[f.Register]++
+		payload, ok := r.values[f.Register]
+		if !ok {
+			payload = noValue
+		}
+		res := Frame{Sender: r.id, Receiver: f.Sender, Type: Response, Register: f.Register, Payload: payload}
+		frm, _ := res.Marshal()
+		r.t.Publish(frm)
+	}
+}
+
+func (r *responder) set(register uint16, payload []byte) {
+	r.mu.Lock()
+	r.values[register] = payload
+	r.mu.Unlock()
+}
+
+func TestVirtualBusDelivery(t *testing.T) {
+	bus := NewVirtualBus(VirtualBusOptions{})
+	defer bus.Close()
+
+	a, b, c := bus.Connect(), bus.Connect(), bus.Connect()
+
+	received := make(chan can.Frame, 3)
+	for _, node := range []Transport{a, b, c} {
+		node.Subscribe(func(frm can.Frame) { received <- frm })
+	}
+
+	a.Publish(canFrame(0x680, 0x31, 0x00, 0x0C))
+
+	for i := 0; i < 2; i++ {
+		select {
+		case frm := <-received:
+			if frm.ID != 0x680 {
+				t.Errorf("Frame incorrect, got: %X, want: 680.", frm.ID)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Frame not delivered")
+		}
+	}
+
+	select {
+	case <-received:
+		t.Errorf("Frame delivered to sender")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	if traffic := bus.Traffic(); len(traffic) != 1 || traffic[0].Dropped {
+		t.Errorf("Traffic incorrect, got: %v.", traffic)
+	}
+
+	c.Close()
+	if err := c.Publish(canFrame(0x680, 0x31, 0x00, 0x0C)); err == nil {
+		t.Errorf("Expected error publishing on closed participant")
+	}
+}
+
+func TestVirtualBusReorder(t *testing.T) {
+	bus := NewVirtualBus(VirtualBusOptions{Latency: time.Millisecond, Jitter: 20 * time.Millisecond, Seed: 1})
+	defer bus.Close()
+
+	a, b := bus.Connect(), bus.Connect()
+
+	received := make(chan uint32, 10)
+	b.Subscribe(func(frm can.Frame) { received <- frm.ID })
+
+	for id := uint32(1); id <= 10; id++ {
+		a.Publish(canFrame(id, 0x31, 0x00, 0x0C))
+	}
+
+	reordered := false
+	last := uint32(0)
+	for i := 0; i < 10; i++ {
+		id := <-received
+		reordered = reordered || id < last
+		last = id
+	}
+
+	if !reordered {
+		t.Errorf("Expected frames to be reordered")
+	}
+}